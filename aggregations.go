@@ -2,6 +2,9 @@ package godruid
 
 import (
 	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
 )
 
 type Aggregation struct {
@@ -83,3 +86,154 @@ func AggCardinality(name string, fieldNames []string, byRow ...bool) Aggregation
 		ByRow:      isByRow,
 	}
 }
+
+// valueType returns the type of the aggregated values, or empty if it is not known.
+func (a Aggregation) valueType() ValueType {
+	switch a.Type {
+	case "count", "longSum", "longMin", "longMax", "longFirst", "longLast":
+		return ValueLong
+	case "doubleSum", "doubleMin", "doubleMax", "doubleFirst", "doubleLast", "min", "max",
+		"javascript", "cardinality", "hyperUnique":
+		return ValueDouble
+	case "floatSum", "floatMin", "floatMax", "floatFirst", "floatLast":
+		return ValueFloat
+	}
+	return ""
+}
+
+// combinable reports whether the results of the aggregation over different intervals
+// could be combined on client side, e.g. while merging the results of QuerySplit.
+func (a Aggregation) combinable() bool {
+	switch a.Type {
+	case "count", "longSum", "doubleSum", "floatSum",
+		"min", "doubleMin", "longMin", "floatMin",
+		"max", "doubleMax", "longMax", "floatMax":
+		return true
+	}
+	return false
+}
+
+// combine combines two results of the aggregation, x or y could be nil.
+func (a Aggregation) combine(x, y interface{}) interface{} {
+	if x == nil {
+		return y
+	}
+	if y == nil {
+		return x
+	}
+	switch a.Type {
+	case "min", "doubleMin", "longMin", "floatMin":
+		if compareNumbers(y, x) < 0 {
+			return y
+		}
+		return x
+	case "max", "doubleMax", "longMax", "floatMax":
+		if compareNumbers(y, x) > 0 {
+			return y
+		}
+		return x
+	}
+	return addNumbers(x, y)
+}
+
+// toInt64 converts v to int64 if it is an integer without losing precision.
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	if i, ok := toInt64(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// addNumbers adds two numbers, the integers are added as int64 to keep the precision.
+func addNumbers(x, y interface{}) interface{} {
+	if xi, ok := toInt64(x); ok {
+		if yi, ok := toInt64(y); ok {
+			return xi + yi
+		}
+	}
+	xf, _ := toFloat64(x)
+	yf, _ := toFloat64(y)
+	return xf + yf
+}
+
+func compareNumbers(x, y interface{}) int {
+	if xi, ok := toInt64(x); ok {
+		if yi, ok := toInt64(y); ok {
+			switch {
+			case xi < yi:
+				return -1
+			case xi > yi:
+				return 1
+			}
+			return 0
+		}
+	}
+	xf, _ := toFloat64(x)
+	yf, _ := toFloat64(y)
+	switch {
+	case xf < yf:
+		return -1
+	case xf > yf:
+		return 1
+	}
+	return 0
+}
+
+// toNumber converts the int64 and float64 values computed on client side to json.Number,
+// rendered the same as Druid does, so the merged rows look like the ones from the broker.
+func toNumber(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int64:
+		return json.Number(strconv.FormatInt(n, 10))
+	case float64:
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return n
+		}
+		return json.Number(formatDouble(n, 64))
+	}
+	return v
+}
+
+// formatDouble formats f as Java's Double.toString, e.g. 6.0 and 1.0E7, or as
+// Float.toString if bitSize is 32.
+func formatDouble(f float64, bitSize int) string {
+	if abs := math.Abs(f); abs == 0 || (abs >= 1e-3 && abs < 1e7) {
+		s := strconv.FormatFloat(f, 'f', -1, bitSize)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+	s := strconv.FormatFloat(f, 'E', -1, bitSize)
+	i := strings.IndexByte(s, 'E')
+	mantissa, exp := s[:i], s[i+1:]
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	e, _ := strconv.Atoi(exp)
+	return mantissa + "E" + strconv.Itoa(e)
+}
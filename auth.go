@@ -0,0 +1,129 @@
+package godruid
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// TokenSource returns the bearer tokens, e.g. the OIDC tokens. It's called for every
+// request, so it should cache the tokens until they expire like RefreshingTokenSource.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc is a function as a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) { return f(ctx) }
+
+// StaticToken returns the TokenSource which always returns token.
+func StaticToken(token string) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (string, error) { return token, nil })
+}
+
+// DefaultTokenRefreshMargin is how long before expiry RefreshingTokenSource refreshes the token.
+const DefaultTokenRefreshMargin = time.Minute
+
+// RefreshingTokenSource caches the token from Fetch, and fetches a new one when it's
+// going to expire in Margin. Only one Fetch is running at a time.
+type RefreshingTokenSource struct {
+	Fetch  func(ctx context.Context) (token string, expiry time.Time, err error)
+	Margin time.Duration // DefaultTokenRefreshMargin if 0.
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func NewRefreshingTokenSource(fetch func(ctx context.Context) (token string, expiry time.Time, err error)) *RefreshingTokenSource {
+	return &RefreshingTokenSource{Fetch: fetch}
+}
+
+func (s *RefreshingTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	margin := s.Margin
+	if margin == 0 {
+		margin = DefaultTokenRefreshMargin
+	}
+	if s.token != "" && time.Now().Add(margin).Before(s.expiry) {
+		return s.token, nil
+	}
+	token, expiry, err := s.Fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expiry = token, expiry
+	return token, nil
+}
+
+// authorize sets the credentials of Client on req.
+func (c *Client) authorize(ctx context.Context, req *http.Request) error {
+	switch {
+	case c.BasicAuth != nil && c.TokenSource != nil:
+		return fmt.Errorf("godruid: BasicAuth and TokenSource can't be both set")
+	case c.BasicAuth != nil:
+		req.SetBasicAuth(c.BasicAuth.Username, c.BasicAuth.Password)
+	case c.TokenSource != nil:
+		token, err := c.TokenSource.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("godruid: failed to get token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// TLSFiles are the PEM files of a TLS configuration.
+type TLSFiles struct {
+	CAFile   string // The CA bundle to verify the broker, the system roots if empty.
+	CertFile string // The client certificate for mutual TLS, optional.
+	KeyFile  string // The key of CertFile.
+}
+
+// LoadTLSConfig returns the TLS configuration with the files loaded.
+func LoadTLSConfig(files TLSFiles) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if files.CAFile != "" {
+		pem, err := ioutil.ReadFile(files.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("godruid: no certificates found in %s", files.CAFile)
+		}
+	}
+	if files.CertFile != "" || files.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// The transports of the TLS configurations, shared by the requests to keep the connections alive.
+var tlsTransports sync.Map
+
+func tlsTransport(cfg *tls.Config) http.RoundTripper {
+	if t, ok := tlsTransports.Load(cfg); ok {
+		return t.(http.RoundTripper)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = cfg
+	actual, _ := tlsTransports.LoadOrStore(cfg, t)
+	return actual.(http.RoundTripper)
+}
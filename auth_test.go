@@ -0,0 +1,105 @@
+package godruid
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuth(t *testing.T) {
+	Convey("TestAuth", t, func() {
+		var header http.Header
+		var peerCerts int
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header
+			if r.TLS != nil {
+				peerCerts = len(r.TLS.PeerCertificates)
+			}
+			w.Write([]byte(`[]`))
+		})
+		query := &QueryTimeseries{
+			DataSource:   "events",
+			Intervals:    []string{"2015-01-01/2015-01-02"},
+			Granularity:  GranAll,
+			Aggregations: []Aggregation{AggCount("count")},
+		}
+
+		Convey("basic auth and headers", func() {
+			server := httptest.NewServer(handler)
+			defer server.Close()
+			client := Client{
+				Url:       server.URL,
+				Header:    http.Header{"X-Team": {"ads"}},
+				BasicAuth: &BasicAuth{Username: "druid", Password: "secret"},
+			}
+			So(client.Query(query), ShouldEqual, nil)
+			So(header.Get("X-Team"), ShouldEqual, "ads")
+			So(header.Get("Authorization"), ShouldEqual, "Basic ZHJ1aWQ6c2VjcmV0")
+
+			client.TokenSource = StaticToken("t")
+			So(client.Query(query), ShouldNotEqual, nil)
+		})
+
+		Convey("refreshing tokens", func() {
+			server := httptest.NewServer(handler)
+			defer server.Close()
+			fetches := 0
+			source := NewRefreshingTokenSource(func(ctx context.Context) (string, time.Time, error) {
+				fetches++
+				// The second token expires within the margin, so it's refreshed every time.
+				return []string{"t1", "t2", "t3"}[fetches-1], time.Now().Add(time.Duration(3-fetches) * time.Hour), nil
+			})
+			client := Client{Url: server.URL, TokenSource: source}
+			So(client.Query(query), ShouldEqual, nil)
+			So(client.Query(query), ShouldEqual, nil)
+			So(header.Get("Authorization"), ShouldEqual, "Bearer t1")
+			So(fetches, ShouldEqual, 1)
+
+			source.Margin = 2 * time.Hour
+			So(client.Query(query), ShouldEqual, nil)
+			So(header.Get("Authorization"), ShouldEqual, "Bearer t2")
+			So(client.Query(query), ShouldEqual, nil)
+			So(header.Get("Authorization"), ShouldEqual, "Bearer t3")
+		})
+
+		Convey("mutual TLS", func() {
+			server := httptest.NewUnstartedServer(handler)
+			server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+			server.StartTLS()
+			defer server.Close()
+
+			dir := t.TempDir()
+			cert := server.TLS.Certificates[0]
+			key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+			So(err, ShouldEqual, nil)
+			files := TLSFiles{
+				CAFile:   filepath.Join(dir, "ca.pem"),
+				CertFile: filepath.Join(dir, "cert.pem"),
+				KeyFile:  filepath.Join(dir, "key.pem"),
+			}
+			certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+			So(os.WriteFile(files.CAFile, certPEM, 0600), ShouldEqual, nil)
+			So(os.WriteFile(files.CertFile, certPEM, 0600), ShouldEqual, nil)
+			So(os.WriteFile(files.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600), ShouldEqual, nil)
+
+			cfg, err := LoadTLSConfig(files)
+			So(err, ShouldEqual, nil)
+			client := Client{Url: server.URL, TLSConfig: cfg}
+			So(client.Query(query), ShouldEqual, nil)
+			So(peerCerts, ShouldEqual, 1)
+
+			cfg, err = LoadTLSConfig(TLSFiles{})
+			So(err, ShouldEqual, nil)
+			client.TLSConfig = cfg
+			So(client.Query(query), ShouldNotEqual, nil)
+		})
+	})
+}
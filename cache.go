@@ -0,0 +1,234 @@
+package godruid
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCacheTTL       = 5 * time.Minute
+	DefaultCacheRecentTTL = 10 * time.Second
+	DefaultCacheETagTTL   = 24 * time.Hour
+)
+
+// Cache stores the raw responses of the queries, keyed by the fingerprints of the queries
+// with the broker URLs and endpoints, so the clients of different brokers could share it.
+// It must be safe for concurrent use. The external stores could treat their errors as misses.
+type Cache interface {
+	Get(key string) (value []byte, ok bool)
+	// Set stores the value for ttl, ttl <= 0 means it never expires.
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+type cacheOptionsKey struct{}
+
+type cacheOptions struct {
+	ttl    time.Duration
+	bypass bool
+}
+
+func cacheOptionsFrom(ctx context.Context) cacheOptions {
+	opts, _ := ctx.Value(cacheOptionsKey{}).(cacheOptions)
+	return opts
+}
+
+// WithCacheTTL sets the cache TTL of the queries sent with ctx, overriding the TTLs of Client.
+func WithCacheTTL(ctx context.Context, ttl time.Duration) context.Context {
+	opts := cacheOptionsFrom(ctx)
+	opts.ttl = ttl
+	return context.WithValue(ctx, cacheOptionsKey{}, opts)
+}
+
+// WithCacheBypass makes the queries sent with ctx skip the cached responses.
+// The fresh responses are still cached.
+func WithCacheBypass(ctx context.Context) context.Context {
+	opts := cacheOptionsFrom(ctx)
+	opts.bypass = true
+	return context.WithValue(ctx, cacheOptionsKey{}, opts)
+}
+
+// A query touches now if any of its intervals ends later than a minute ago,
+// the data ingested recently could still be changing.
+const recentWindow = time.Minute
+
+// queryCached sends the query through the cache if there is one.
+func (c *Client) queryCached(ctx context.Context, query Query, reqJson []byte) ([]byte, error) {
+	if c.Cache == nil {
+		return c.QueryRawWithContext(ctx, reqJson)
+	}
+	x := responseExchangeFrom(ctx)
+	if x == nil {
+		ctx, x = withResponseExchange(ctx)
+	}
+	fp, err := fingerprintOnce(ctx, reqJson)
+	if err != nil {
+		return nil, err
+	}
+	key := c.cacheKey(fp)
+	opts := cacheOptionsFrom(ctx)
+	if !opts.bypass {
+		result, ok := c.Cache.Get(key)
+		if c.Metrics != nil {
+			c.Metrics.CacheLookup(c.metricLabels(reqJson), ok)
+		}
+		if ok {
+			x.meta = &ResponseMeta{Cached: true}
+			return result, nil
+		}
+	}
+
+	// Revalidate the last response with its ETag.
+	etagKey := key + ".etag"
+	var last []byte
+	if c.CacheETags {
+		if entry, ok := c.Cache.Get(etagKey); ok {
+			x.ifNoneMatch, last, _ = parseETagEntry(entry)
+		}
+	}
+	result, err := c.QueryRawWithContext(ctx, reqJson)
+	x.ifNoneMatch = ""
+	if err != nil {
+		return nil, err
+	}
+	if x.meta != nil && x.meta.NotModified {
+		result = last
+	}
+	// The missing data could come back any time, don't keep the partial results.
+	if x.meta.Incomplete() {
+		return result, nil
+	}
+
+	// The intervals which can't be parsed are cached as the recent ones.
+	intervals, _ := ParseIntervals(query.getIntervals())
+	c.Cache.Set(key, result, c.cacheTTL(intervals, opts))
+	if c.CacheETags && x.meta != nil && x.meta.ETag != "" && !x.meta.NotModified {
+		ttl := c.CacheETagTTL
+		if ttl == 0 {
+			ttl = DefaultCacheETagTTL
+		}
+		c.Cache.Set(etagKey, etagEntry(x.meta.ETag, result), ttl)
+	}
+	return result, nil
+}
+
+// cacheKey returns the key in Cache of the query with the fingerprint fp sent by c.
+func (c *Client) cacheKey(fp string) string {
+	endPoint := c.EndPoint
+	if endPoint == "" {
+		endPoint = DefaultEndPoint
+	}
+	sum := sha256.Sum256([]byte(c.Url + endPoint + "\n" + fp))
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheTTL returns the TTL of the results of intervals.
+func (c *Client) cacheTTL(intervals Intervals, opts cacheOptions) time.Duration {
+	if opts.ttl != 0 {
+		return opts.ttl
+	}
+	if touchesNow(intervals) {
+		if c.CacheRecentTTL != 0 {
+			return c.CacheRecentTTL
+		}
+		return DefaultCacheRecentTTL
+	}
+	if c.CacheTTL != 0 {
+		return c.CacheTTL
+	}
+	return DefaultCacheTTL
+}
+
+// touchesNow reports whether the intervals reach the recent data, no intervals means all the data.
+func touchesNow(intervals Intervals) bool {
+	if len(intervals) == 0 {
+		return true
+	}
+	return intervals.Span().End.After(time.Now().Add(-recentWindow))
+}
+
+// ---------------------------------
+// LRU Cache
+// ---------------------------------
+
+// LRUCache is an in-memory Cache which evicts the least recently used entries when
+// the total size of the keys and values exceeds MaxBytes.
+type LRUCache struct {
+	maxBytes int64
+
+	mu      sync.Mutex
+	bytes   int64
+	entries map[string]*list.Element
+	order   *list.List // Front is the most recently used.
+}
+
+type lruEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time // Zero means never expires.
+}
+
+func (e *lruEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+func NewLRUCache(maxBytes int64) *LRUCache {
+	return &LRUCache{
+		maxBytes: maxBytes,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if !e.expireAt.IsZero() && time.Now().After(e.expireAt) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+func (c *LRUCache) Set(key string, value []byte, ttl time.Duration) {
+	e := &lruEntry{key: key, value: value}
+	if ttl > 0 {
+		e.expireAt = time.Now().Add(ttl)
+	}
+	if e.size() > c.maxBytes {
+		return // Never fits.
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.order.PushFront(e)
+	c.bytes += e.size()
+	for c.bytes > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// Len returns the number of the cached entries, including the expired ones not evicted yet.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	e := c.order.Remove(el).(*lruEntry)
+	delete(c.entries, e.key)
+	c.bytes -= e.size()
+}
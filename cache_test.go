@@ -0,0 +1,100 @@
+package godruid
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	Convey("TestLRUCache", t, func() {
+		cache := NewLRUCache(20)
+		cache.Set("a", []byte("12345"), 0)
+		cache.Set("b", []byte("12345"), 0)
+		cache.Set("c", []byte("12345"), 0)
+		So(cache.Len(), ShouldEqual, 3)
+
+		_, ok := cache.Get("a")
+		So(ok, ShouldBeTrue)
+		cache.Set("d", []byte("12345"), 0)
+		_, ok = cache.Get("b")
+		So(ok, ShouldBeFalse)
+		_, ok = cache.Get("a")
+		So(ok, ShouldBeTrue)
+
+		cache.Set("big", make([]byte, 100), 0)
+		_, ok = cache.Get("big")
+		So(ok, ShouldBeFalse)
+
+		cache.Set("e", []byte("x"), time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		_, ok = cache.Get("e")
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestClientCache(t *testing.T) {
+	Convey("TestClientCache", t, func() {
+		server, got := fakeBroker(map[string]string{
+			"2015-01-01": `[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":3}}]`,
+		})
+		defer server.Close()
+
+		newQuery := func(queryId string) *QueryTimeseries {
+			return &QueryTimeseries{
+				DataSource:   "events",
+				Intervals:    []string{"2015-01-01/2015-01-02"},
+				Granularity:  GranAll,
+				Aggregations: []Aggregation{AggCount("count")},
+				Context:      &QueryContext{QueryId: queryId},
+			}
+		}
+		client := Client{Url: server.URL, Cache: NewLRUCache(1 << 20)}
+		for _, id := range []string{"q1", "q2"} {
+			query := newQuery(id)
+			So(client.Query(query), ShouldEqual, nil)
+			count, err := query.QueryResult[0].Result.Int64("count")
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 3)
+		}
+		So(len(*got), ShouldEqual, 1)
+
+		So(client.QueryWithContext(WithCacheBypass(context.Background()), newQuery("q3")), ShouldEqual, nil)
+		So(len(*got), ShouldEqual, 2)
+
+		So(client.cacheTTL(Intervals{MustParseInterval("2015-01-01/2015-01-02")}, cacheOptions{}), ShouldEqual, DefaultCacheTTL)
+		recent := Intervals{LastN(time.Hour)}
+		So(client.cacheTTL(recent, cacheOptions{}), ShouldEqual, DefaultCacheRecentTTL)
+		So(client.cacheTTL(recent, cacheOptions{ttl: time.Hour}), ShouldEqual, time.Hour)
+	})
+}
+
+func TestSharedCache(t *testing.T) {
+	Convey("TestSharedCache", t, func() {
+		server1, _ := fakeBroker(map[string]string{
+			"2015-01-01": `[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":1}}]`,
+		})
+		defer server1.Close()
+		server2, _ := fakeBroker(map[string]string{
+			"2015-01-01": `[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":2}}]`,
+		})
+		defer server2.Close()
+
+		cache := NewLRUCache(1 << 20)
+		for i, url := range []string{server1.URL, server2.URL} {
+			client := Client{Url: url, Cache: cache}
+			query := &QueryTimeseries{
+				DataSource:   "events",
+				Intervals:    []string{"2015-01-01/2015-01-02"},
+				Granularity:  GranAll,
+				Aggregations: []Aggregation{AggCount("count")},
+			}
+			So(client.Query(query), ShouldEqual, nil)
+			count, err := query.QueryResult[0].Result.Int64("count")
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, i+1)
+		}
+		So(cache.Len(), ShouldEqual, 2)
+	})
+}
@@ -2,9 +2,11 @@ package godruid
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"io"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -17,56 +19,229 @@ type Client struct {
 	Url      string
 	EndPoint string
 	Timeout  time.Duration
+	// Transport sends the HTTP requests, http.DefaultTransport if nil.
+	Transport http.RoundTripper
 
-	Debug        bool
-	LastRequest  string
-	LastResponse string
+	// Header is sent with every request, e.g. the headers required by a proxy.
+	Header http.Header
+	// BasicAuth sends the credentials of HTTP basic auth, e.g. for the basic-security extension.
+	BasicAuth *BasicAuth
+	// TokenSource sends its tokens as the bearer tokens, it can't be set with BasicAuth.
+	TokenSource TokenSource
+	// DisableCompression asks for the uncompressed responses, instead of the gzip or
+	// deflate compressed ones.
+	DisableCompression bool
+	// CompressRequestsOver gzips the queries of at least this many bytes, e.g. the ones
+	// with huge "in" filters. 0 disables it.
+	CompressRequestsOver int
+	// Smile asks for the responses of Query in the Smile binary format, which are smaller
+	// and faster for the broker to write, and are decoded into the results directly. The
+	// responses seen by the interceptors and Tracers, and the cached ones, are in Smile.
+	// QueryRaw and QueryIncremental still ask for JSON.
+	Smile bool
+
+	// TLSConfig is used to connect the broker with TLS, e.g. LoadTLSConfig for mutual TLS.
+	// It's ignored if Transport is set, configure the TLS of Transport instead.
+	TLSConfig *tls.Config
+
+	// DefaultContext is merged into the context of every query, the values set on the query win.
+	DefaultContext *QueryContext
+	// DataSourceContext overrides DefaultContext for the queries on the specific datasource,
+	// e.g. a lower priority for a heavy datasource.
+	DataSourceContext map[string]*QueryContext
+
+	// Cache caches the responses of the queries, nil disables caching.
+	Cache Cache
+	// CacheTTL is how long the responses are cached, DefaultCacheTTL if 0.
+	CacheTTL time.Duration
+	// CacheRecentTTL is the TTL of the queries whose intervals touch now, as their results
+	// are still changing. DefaultCacheRecentTTL if 0.
+	CacheRecentTTL time.Duration
+
+	// CacheETags keeps the ETags of the responses in Cache for CacheETagTTL, DefaultCacheETagTTL
+	// if 0, and revalidates the expired responses with If-None-Match, so the unchanged
+	// results are not sent again. The results must be cached by the result-level cache
+	// of Druid for the ETags.
+	CacheETags   bool
+	CacheETagTTL time.Duration
+	// FailOnIncomplete fails the queries if the broker tells that some data is missing,
+	// i.e. uncoveredIntervals or missingSegments in the response context.
+	FailOnIncomplete bool
+
+	// Limiter limits the rate and concurrency of the queries, nil disables it.
+	Limiter *Limiter
+
+	// QueryIdFunc generates the queryIds of the queries without one, NewQueryId if nil.
+	QueryIdFunc func() string
+	// Registry keeps the queries running, nil disables it.
+	Registry *QueryRegistry
+
+	// Coalescer coalesces the concurrent identical queries into one request, nil disables it.
+	Coalescer *Coalescer
+
+	// Debug sends the queries indented and asks for the pretty responses, use Tracer to see them.
+	Debug bool
+	// Tracer traces the requests to the broker, nil disables tracing.
+	Tracer Tracer
+	// Logger logs every request to the broker, with the request and response bodies
+	// at the debug level. nil disables logging.
+	Logger *slog.Logger
+	// LogRedact tells whether the filter values on dimension should be redacted in the logs.
+	LogRedact func(dimension string) bool
+	// Metrics records the metrics of the queries, nil disables it.
+	Metrics MetricsRecorder
+
+	// Interceptors wrap the queries sent by Query, the first one is the outermost one.
+	// The coalescing and caching of the queries are inside the interceptors.
+	Interceptors []Interceptor
 }
 
 func (c *Client) Query(query Query) (err error) {
-	query.setup()
-	var reqJson []byte
-	if c.Debug {
-		reqJson, err = json.MarshalIndent(query, "", "  ")
-	} else {
-		reqJson, err = json.Marshal(query)
-	}
+	return c.QueryWithContext(context.Background(), query)
+}
+
+// QueryWithContext sends the query, ctx could cancel the request, and carries the options
+// like WithCacheTTL.
+func (c *Client) QueryWithContext(ctx context.Context, query Query) (err error) {
+	reqJson, err := c.marshalQuery(query)
 	if err != nil {
 		return
 	}
-	result, err := c.QueryRaw(reqJson)
+	ctx, x := withResponseExchange(ctx)
+	ctx, hooks := withDecodeHooks(ctx, query)
+	hooks.smile = c.Smile
+	defer func() { hooks.run(err) }()
+	result, err := c.intercept(c.queryCoalesced)(ctx, query, reqJson)
 	if err != nil {
 		return
 	}
+	query.setResponseMeta(x.meta)
 
 	return query.onResponse(result)
 }
 
-func (c *Client) QueryRaw(req []byte) (result []byte, err error) {
-	if c.EndPoint == "" {
-		c.EndPoint = DefaultEndPoint
+// marshalQuery sets up query and marshals it with the merged context.
+func (c *Client) marshalQuery(query Query) (reqJson []byte, err error) {
+	query.setup()
+
+	// Only send the merged context, leave the one of query untouched.
+	queryCtx := query.getContext()
+	sendCtx := c.queryContext(query)
+	if sendCtx == nil {
+		sendCtx = &QueryContext{}
 	}
+	if sendCtx.QueryId == "" {
+		sendCtx.QueryId = c.newQueryId()
+	}
+	query.setContext(sendCtx)
+	if c.Debug {
+		reqJson, err = json.MarshalIndent(query, "", "  ")
+	} else {
+		reqJson, err = json.Marshal(query)
+	}
+	query.setContext(queryCtx)
+	return
+}
+
+// queryContext returns the context which should be sent with query.
+func (c *Client) queryContext(query Query) *QueryContext {
+	defaults := c.DefaultContext
+	if dsCtx, ok := c.DataSourceContext[query.getDataSource()]; ok {
+		defaults = dsCtx.Merge(defaults)
+	}
+	return query.getContext().Merge(defaults)
+}
+
+func (c *Client) QueryRaw(req []byte) (result []byte, err error) {
+	return c.QueryRawWithContext(context.Background(), req)
+}
+
+func (c *Client) QueryRawWithContext(ctx context.Context, req []byte) (result []byte, err error) {
 	endPoint := c.EndPoint
+	if endPoint == "" {
+		endPoint = DefaultEndPoint
+	}
 	if c.Debug {
 		endPoint += "?pretty"
-		c.LastRequest = string(req)
-	}
-	if err != nil {
-		return
 	}
 
-	// By default, use 60 second timeout unless specified otherwise
-	// by the caller
-	clientTimeout := 60 * time.Second
-	if c.Timeout != 0 {
-		clientTimeout = c.Timeout
+	// The queries rejected or canceled while waiting for the Limiter are traced as well.
+	var trace *Trace
+	var labels MetricLabels
+	started := false
+	if c.Tracer != nil || c.Logger != nil || c.Metrics != nil {
+		trace = &Trace{QueryId: queryIdOf(req), URL: c.Url + endPoint, Request: req, Attempt: Attempt(ctx)}
+		if c.Tracer != nil {
+			c.Tracer.OnRequest(ctx, trace.QueryId, req)
+		}
+		if c.Metrics != nil {
+			labels = c.metricLabels(req)
+		}
+		start := time.Now()
+		defer func() {
+			trace.Duration = time.Since(start)
+			finish := func(_ Query, err error) {
+				trace.Err = err
+				if c.Tracer != nil {
+					c.Tracer.OnResponse(ctx, trace)
+				}
+				if c.Logger != nil {
+					c.logRequest(ctx, trace)
+				}
+				if c.Metrics != nil {
+					if !started {
+						// Rejected by the Limiter, it's still counted as a failed query.
+						c.Metrics.QueryStarted(labels)
+					}
+					c.Metrics.QueryDone(labels, trace.Duration, len(trace.Response), err)
+				}
+			}
+			// Wait for the response to be decoded, which could fail as well.
+			if err != nil || !AfterDecode(ctx, finish) {
+				finish(nil, err)
+			}
+		}()
 	}
 
-	httpClient := &http.Client{
-		Timeout: clientTimeout,
+	if c.Limiter != nil {
+		labels := c.metricLabels(req)
+		start := time.Now()
+		release, err := c.Limiter.acquire(ctx, labels.QueryType, labels.DataSource)
+		if recorder, ok := c.Metrics.(QueueWaitRecorder); ok {
+			recorder.QueueWait(labels, time.Since(start))
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+	if c.Registry != nil {
+		defer c.Registry.add(req)()
+	}
+	if c.Metrics != nil {
+		if recorder, ok := c.Metrics.(RetryRecorder); ok && trace.Attempt > 1 {
+			recorder.QueryRetried(labels)
+		}
+		c.Metrics.QueryStarted(labels)
+		started = true
 	}
 
-	resp, err := httpClient.Post(c.Url+endPoint, "application/json", bytes.NewBuffer(req))
+	httpReq, err := c.newRequest(ctx, http.MethodPost, c.Url+endPoint, bytes.NewBuffer(req))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	x := responseExchangeFrom(ctx)
+	if x != nil && x.ifNoneMatch != "" {
+		httpReq.Header.Set("If-None-Match", x.ifNoneMatch)
+	}
+	if h := decodeHooksFrom(ctx); h != nil && h.smile {
+		httpReq.Header.Set("Accept", SmileContentType+", application/json")
+	}
+	if err = c.encodeRequest(httpReq, req); err != nil {
+		return
+	}
+	resp, err := c.httpClient().Do(httpReq)
 	if err != nil {
 		return
 	}
@@ -74,17 +249,74 @@ func (c *Client) QueryRaw(req []byte) (result []byte, err error) {
 		resp.Body.Close()
 	}()
 
-	result, err = ioutil.ReadAll(resp.Body)
+	result, err = readResponse(resp)
+	if trace != nil {
+		trace.Status = resp.StatusCode
+		trace.Response = result
+	}
 	if err != nil {
 		return
 	}
-	if c.Debug {
-		c.LastResponse = string(result)
-	}
 
+	if resp.StatusCode == http.StatusNotModified && x != nil && x.ifNoneMatch != "" {
+		x.meta = &ResponseMeta{QueryId: resp.Header.Get("X-Druid-Query-Id"), ETag: x.ifNoneMatch, NotModified: true}
+		return nil, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s: %s", resp.Status, string(result))
+		return nil, newDruidError(resp, result)
 	}
 
+	meta, err := parseResponseMeta(resp.Header)
+	if err != nil {
+		return nil, err
+	}
+	if c.FailOnIncomplete && meta.Incomplete() {
+		return nil, &IncompleteResultError{Meta: meta}
+	}
+	if x != nil {
+		x.meta = meta
+	}
 	return
 }
+
+func (c *Client) httpClient() *http.Client {
+	// By default, use 60 second timeout unless specified otherwise
+	// by the caller
+	clientTimeout := 60 * time.Second
+	if c.Timeout != 0 {
+		clientTimeout = c.Timeout
+	}
+
+	return &http.Client{
+		Transport: c.BaseTransport(),
+		Timeout:   clientTimeout,
+	}
+}
+
+// BaseTransport returns the RoundTripper sending the requests: Transport if it's set, the
+// one with TLSConfig if that's set, or http.DefaultTransport. The RoundTrippers wrapping
+// the transport of Client should wrap this one, so TLSConfig is not lost.
+func (c *Client) BaseTransport() http.RoundTripper {
+	switch {
+	case c.Transport != nil:
+		return c.Transport
+	case c.TLSConfig != nil:
+		return tlsTransport(c.TLSConfig)
+	}
+	return http.DefaultTransport
+}
+
+// newRequest returns the request to the broker with the headers and credentials of Client.
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.Header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if err := c.authorize(ctx, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
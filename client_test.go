@@ -1,6 +1,7 @@
 package godruid
 
 import (
+	"context"
 	"fmt"
 	. "github.com/smartystreets/goconvey/convey"
 	"testing"
@@ -23,14 +24,15 @@ func TestGroupby(t *testing.T) {
 		client := Client{
 			Url:   "http://192.168.10.60:8009",
 			Debug: true,
+			Tracer: TracerFunc(func(ctx context.Context, trace *Trace) {
+				fmt.Println("requst", string(trace.Request))
+				fmt.Println("response", string(trace.Response))
+			}),
 		}
 
 		err := client.Query(query)
-		fmt.Println("requst", client.LastRequest)
 		So(err, ShouldEqual, nil)
 
-		fmt.Println("response", client.LastResponse)
-
 		fmt.Printf("query.QueryResult:\n%v", query.QueryResult)
 
 	})
@@ -50,14 +52,15 @@ func TestSearch(t *testing.T) {
 		client := Client{
 			Url:   "http://192.168.10.60:8009",
 			Debug: true,
+			Tracer: TracerFunc(func(ctx context.Context, trace *Trace) {
+				fmt.Println("requst", string(trace.Request))
+				fmt.Println("response", string(trace.Response))
+			}),
 		}
 
 		err := client.Query(query)
 		So(err, ShouldEqual, nil)
 
-		fmt.Println("requst", client.LastRequest)
-		fmt.Println("response", client.LastResponse)
-
 		fmt.Printf("query.QueryResult:\n%v", query.QueryResult)
 
 	})
@@ -0,0 +1,103 @@
+package godruid
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Coalescer coalesces the concurrent identical queries, by Fingerprint, into one request
+// to the broker, and all the callers get the same response. A caller canceling its
+// context only stops waiting, the shared request is canceled when all of the callers
+// have gone. The zero value is ready to use.
+type Coalescer struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	result  []byte
+	meta    *ResponseMeta
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func NewCoalescer() *Coalescer {
+	return &Coalescer{}
+}
+
+// queryCoalesced sends the query through the coalescer if there is one. The shared request
+// takes the context values, e.g. the cache options, of the caller who starts it.
+func (c *Client) queryCoalesced(ctx context.Context, query Query, reqJson []byte) ([]byte, error) {
+	if c.Coalescer == nil {
+		return c.queryCached(ctx, query, reqJson)
+	}
+	key, err := fingerprintOnce(ctx, reqJson)
+	if err != nil {
+		return nil, err
+	}
+	result, meta, err := c.Coalescer.do(ctx, key, func(ctx context.Context) ([]byte, *ResponseMeta, error) {
+		ctx, x := withResponseExchange(ctx)
+		x.fingerprint = key
+		result, err := c.queryCached(ctx, query, reqJson)
+		return result, x.meta, err
+	})
+	if x := responseExchangeFrom(ctx); x != nil {
+		x.meta = meta
+	}
+	return result, err
+}
+
+func (g *Coalescer) do(ctx context.Context, key string, fn func(context.Context) ([]byte, *ResponseMeta, error)) ([]byte, *ResponseMeta, error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+	f, ok := g.flights[key]
+	if !ok {
+		shared, cancel := context.WithCancel(detachedContext{ctx})
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+		go func() {
+			f.result, f.meta, f.err = fn(shared)
+			g.mu.Lock()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+			g.mu.Unlock()
+			cancel()
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.result, f.meta, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Nobody is waiting, and the later callers start a new request.
+			f.cancel()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, nil, ctx.Err()
+	}
+}
+
+// detachedContext keeps the values of its parent but not the cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) { return }
+func (detachedContext) Done() <-chan struct{}                   { return nil }
+func (detachedContext) Err() error                              { return nil }
+func (c detachedContext) Value(key interface{}) interface{}     { return c.parent.Value(key) }
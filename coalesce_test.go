@@ -0,0 +1,66 @@
+package godruid
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescer(t *testing.T) {
+	Convey("TestCoalescer", t, func() {
+		var requests int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			<-release
+			w.Write([]byte(`[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":3}}]`))
+		}))
+		defer server.Close()
+
+		client := Client{Url: server.URL, Coalescer: NewCoalescer()}
+		newQuery := func() *QueryTimeseries {
+			return &QueryTimeseries{
+				DataSource:   "events",
+				Intervals:    []string{"2015-01-01/2015-01-02"},
+				Granularity:  GranAll,
+				Aggregations: []Aggregation{AggCount("count")},
+			}
+		}
+
+		// A canceled caller doesn't cancel the shared request.
+		canceled, cancel := context.WithCancel(context.Background())
+		canceledErr := make(chan error)
+		go func() { canceledErr <- client.QueryWithContext(canceled, newQuery()) }()
+
+		var wg sync.WaitGroup
+		queries := make([]*QueryTimeseries, 5)
+		errs := make([]error, len(queries))
+		for i := range queries {
+			queries[i] = newQuery()
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = client.QueryWithContext(context.Background(), queries[i])
+			}(i)
+		}
+		for atomic.LoadInt32(&requests) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		So(<-canceledErr, ShouldEqual, context.Canceled)
+		close(release)
+		wg.Wait()
+
+		So(atomic.LoadInt32(&requests), ShouldEqual, 1)
+		for i, query := range queries {
+			So(errs[i], ShouldEqual, nil)
+			So(len(query.QueryResult), ShouldEqual, 1)
+		}
+	})
+}
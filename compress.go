@@ -0,0 +1,63 @@
+package godruid
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// encodeRequest sets the encodings of the request, and gzips the body if it's large enough.
+func (c *Client) encodeRequest(httpReq *http.Request, body []byte) error {
+	// An explicit Accept-Encoding also stops http.Transport asking for gzip by itself.
+	if c.DisableCompression {
+		httpReq.Header.Set("Accept-Encoding", "identity")
+	} else {
+		httpReq.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
+	if c.CompressRequestsOver <= 0 || len(body) < c.CompressRequestsOver {
+		return nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	compressed := buf.Bytes()
+	httpReq.Body = io.NopCloser(bytes.NewReader(compressed))
+	httpReq.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(compressed)), nil }
+	httpReq.ContentLength = int64(len(compressed))
+	httpReq.Header.Set("Content-Encoding", "gzip")
+	return nil
+}
+
+// readResponse reads the body of resp and decompresses it.
+func readResponse(resp *http.Response) ([]byte, error) {
+	var r io.Reader = resp.Body
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip":
+		gr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	case "deflate":
+		zr, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("godruid: unsupported content encoding %q", encoding)
+	}
+	return io.ReadAll(r)
+}
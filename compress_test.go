@@ -0,0 +1,148 @@
+package godruid
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// smileDouble encodes f as a Smile double.
+func smileDouble(f float64) []byte {
+	v := math.Float64bits(f)
+	res := []byte{0x29}
+	for i := 9; i >= 0; i-- {
+		res = append(res, byte(v>>uint(7*i))&0x7F)
+	}
+	return res
+}
+
+func TestSmile(t *testing.T) {
+	Convey("TestSmile", t, func() {
+		doc := []byte(":)\n\x03")
+		doc = append(doc, 0xF8,
+			0xFA, 0x80, 'a', 0xC2, 0x80, 'b', 0x41, 'x', 'y', 0x80, 'c', 0x24, 0x1F, 0x90, 0xFB,
+			0xFA, 0x40, 0xC3, 0x41, 0x01, 0x42, 0x21, 0x84, 'd', 'o', 'u', 'b', 'l')
+		doc = append(doc, smileDouble(1.5)...)
+		doc = append(doc, 0xFB, 0xF9, 0xFF)
+
+		var events []Event
+		So(unmarshalResponse(doc, &events), ShouldEqual, nil)
+		So(events, ShouldResemble, []Event{
+			{"a": json.Number("1"), "b": "xy", "c": json.Number("1000")},
+			{"a": json.Number("-2"), "b": "xy", "c": nil, "doubl": json.Number("1.5")},
+		})
+		So(string(smileText(doc)), ShouldEqual, `[{"a":1,"b":"xy","c":1000},{"a":-2,"b":"xy","c":null,"doubl":1.5}]`)
+
+		var rows []struct {
+			A int64   `json:"a"`
+			B string  `json:"b"`
+			D float64 `json:"doubl"`
+		}
+		So(unmarshalResponse(doc, &rows), ShouldEqual, nil)
+		So(rows[1].A, ShouldEqual, -2)
+		So(rows[1].B, ShouldEqual, "xy")
+		So(rows[1].D, ShouldEqual, 1.5)
+
+		var wrong []struct {
+			B int `json:"b"`
+		}
+		So(ErrorClass(unmarshalResponse(doc, &wrong)), ShouldEqual, "decode")
+		So(ErrorClass(unmarshalResponse(doc[:len(doc)-3], &events)), ShouldEqual, "decode")
+
+		So(decimalString(big.NewInt(-12345), 2), ShouldEqual, "-123.45")
+		So(decimalString(big.NewInt(5), 3), ShouldEqual, "0.005")
+		So(decimalString(big.NewInt(5), -2), ShouldEqual, "5e2")
+		So(bigIntOf([]byte{0xFF, 0xFE}).Int64(), ShouldEqual, -2)
+	})
+}
+
+func TestCompression(t *testing.T) {
+	Convey("TestCompression", t, func() {
+		response := `[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":3}}]`
+		var requestEncoding, acceptEncoding string
+		var requestBody []byte
+		encoding := "gzip"
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestEncoding = r.Header.Get("Content-Encoding")
+			acceptEncoding = r.Header.Get("Accept-Encoding")
+			requestBody, _ = ioutil.ReadAll(r.Body)
+			var buf bytes.Buffer
+			switch encoding {
+			case "gzip":
+				w.Header().Set("Content-Encoding", "gzip")
+				zw := gzip.NewWriter(&buf)
+				zw.Write([]byte(response))
+				zw.Close()
+			case "deflate":
+				w.Header().Set("Content-Encoding", "deflate")
+				zw := zlib.NewWriter(&buf)
+				zw.Write([]byte(response))
+				zw.Close()
+			default:
+				buf.WriteString(response)
+			}
+			w.Write(buf.Bytes())
+		}))
+		defer server.Close()
+
+		query := &QueryTimeseries{
+			DataSource:   "events",
+			Intervals:    []string{"2015-01-01/2015-01-02"},
+			Granularity:  GranAll,
+			Aggregations: []Aggregation{AggCount("count")},
+		}
+		client := Client{Url: server.URL, CompressRequestsOver: 10}
+		So(client.Query(query), ShouldEqual, nil)
+		So(len(query.QueryResult), ShouldEqual, 1)
+		So(acceptEncoding, ShouldEqual, "gzip, deflate")
+		So(requestEncoding, ShouldEqual, "gzip")
+		zr, err := gzip.NewReader(bytes.NewReader(requestBody))
+		So(err, ShouldEqual, nil)
+		body, _ := ioutil.ReadAll(zr)
+		So(string(body), ShouldContainSubstring, `"queryType":"timeseries"`)
+
+		encoding = "deflate"
+		client.CompressRequestsOver = 0
+		So(client.Query(query), ShouldEqual, nil)
+		So(len(query.QueryResult), ShouldEqual, 1)
+		So(requestEncoding, ShouldEqual, "")
+
+		encoding = ""
+		client.DisableCompression = true
+		So(client.Query(query), ShouldEqual, nil)
+		So(len(query.QueryResult), ShouldEqual, 1)
+		So(acceptEncoding, ShouldEqual, "identity")
+
+		Convey("Smile", func() {
+			var accept string
+			smileServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				accept = r.Header.Get("Accept")
+				if !strings.HasPrefix(accept, SmileContentType) {
+					w.Write([]byte(response))
+					return
+				}
+				w.Header().Set("Content-Type", SmileContentType)
+				doc := []byte(":)\n\x03\xF8\xFA\x88timestamp\x572015-01-01T00:00:00.000Z\x85result\xFA\x84count\xC6\xFB\xFB\xF9")
+				w.Write(doc)
+			}))
+			defer smileServer.Close()
+			client := Client{Url: smileServer.URL, Smile: true}
+			So(client.Query(query), ShouldEqual, nil)
+			So(accept, ShouldStartWith, SmileContentType)
+			So(query.QueryResult, ShouldResemble, []Timeseries{{Timestamp: "2015-01-01T00:00:00.000Z", Result: Event{"count": json.Number("3")}}})
+
+			_, err := client.QueryRaw([]byte(`{"queryType":"timeseries"}`))
+			So(err, ShouldEqual, nil)
+			So(accept, ShouldEqual, "")
+		})
+	})
+}
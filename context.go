@@ -0,0 +1,203 @@
+package godruid
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// QueryContext is the "context" object of a query. The documented keys are typed
+// fields, anything else can be put into Extra.
+// Check http://druid.io/docs/latest/querying/query-context.html for detail description.
+//
+// Zero values mean "not set" and are not serialized, use the pointer helpers
+// like Bool() and Int() for the keys where the zero value is meaningful.
+type QueryContext struct {
+	Timeout                  time.Duration // Serialized in milliseconds.
+	Priority                 *int
+	Lane                     string
+	QueryId                  string
+	SqlQueryId               string
+	UseCache                 *bool
+	PopulateCache            *bool
+	UseResultLevelCache      *bool
+	PopulateResultLevelCache *bool
+	BySegment                *bool
+	Finalize                 *bool
+	MaxScatterGatherBytes    int64
+	MaxQueuedBytes           int64
+	MinTopNThreshold         int
+	SkipEmptyBuckets         *bool
+	GrandTotal               *bool
+	GroupByStrategy          string
+	ChunkPeriod              string
+	Vectorize                string // "false", "true" or "force".
+	VectorSize               int
+	SerializeDateTimeAsLong  *bool
+	UncoveredIntervalsLimit  int // The max uncoveredIntervals in the response context, 0 disables them.
+
+	// Extra holds the custom keys. The typed fields win if a key is set in both.
+	Extra map[string]interface{}
+}
+
+const (
+	GroupByStrategyV1 = "v1"
+	GroupByStrategyV2 = "v2"
+
+	VectorizeFalse = "false"
+	VectorizeTrue  = "true"
+	VectorizeForce = "force"
+)
+
+func Bool(b bool) *bool { return &b }
+func Int(i int) *int    { return &i }
+
+// Set puts a custom key into Extra.
+func (c *QueryContext) Set(key string, value interface{}) *QueryContext {
+	if c.Extra == nil {
+		c.Extra = map[string]interface{}{}
+	}
+	c.Extra[key] = value
+	return c
+}
+
+// Merge returns a new context with the values of c on top of defaults.
+// Both c and defaults could be nil.
+func (c *QueryContext) Merge(defaults *QueryContext) *QueryContext {
+	if c == nil && defaults == nil {
+		return nil
+	}
+	m := defaults.toMap()
+	for k, v := range c.toMap() {
+		m[k] = v
+	}
+	return contextFromMap(m)
+}
+
+func (c *QueryContext) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.toMap())
+}
+
+func (c *QueryContext) UnmarshalJSON(data []byte) error {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*c = *contextFromMap(m)
+	return nil
+}
+
+func (c *QueryContext) toMap() map[string]interface{} {
+	m := map[string]interface{}{}
+	if c == nil {
+		return m
+	}
+	for k, v := range c.Extra {
+		m[k] = v
+	}
+	putInt64 := func(key string, v int64) {
+		if v != 0 {
+			m[key] = v
+		}
+	}
+	putString := func(key string, v string) {
+		if v != "" {
+			m[key] = v
+		}
+	}
+	putBool := func(key string, v *bool) {
+		if v != nil {
+			m[key] = *v
+		}
+	}
+	putInt64("timeout", int64(c.Timeout/time.Millisecond))
+	if c.Priority != nil {
+		m["priority"] = *c.Priority
+	}
+	putString("lane", c.Lane)
+	putString("queryId", c.QueryId)
+	putString("sqlQueryId", c.SqlQueryId)
+	putBool("useCache", c.UseCache)
+	putBool("populateCache", c.PopulateCache)
+	putBool("useResultLevelCache", c.UseResultLevelCache)
+	putBool("populateResultLevelCache", c.PopulateResultLevelCache)
+	putBool("bySegment", c.BySegment)
+	putBool("finalize", c.Finalize)
+	putInt64("maxScatterGatherBytes", c.MaxScatterGatherBytes)
+	putInt64("maxQueuedBytes", c.MaxQueuedBytes)
+	putInt64("minTopNThreshold", int64(c.MinTopNThreshold))
+	putBool("skipEmptyBuckets", c.SkipEmptyBuckets)
+	putBool("grandTotal", c.GrandTotal)
+	putString("groupByStrategy", c.GroupByStrategy)
+	putString("chunkPeriod", c.ChunkPeriod)
+	putString("vectorize", c.Vectorize)
+	putInt64("vectorSize", int64(c.VectorSize))
+	putInt64("uncoveredIntervalsLimit", int64(c.UncoveredIntervalsLimit))
+	putBool("serializeDateTimeAsLong", c.SerializeDateTimeAsLong)
+	return m
+}
+
+func contextFromMap(m map[string]interface{}) *QueryContext {
+	c := &QueryContext{}
+	takeInt64 := func(key string) int64 {
+		v, ok := m[key]
+		if !ok {
+			return 0
+		}
+		delete(m, key)
+		switch n := v.(type) {
+		case int:
+			return int64(n)
+		case int64:
+			return n
+		case float64:
+			return int64(n)
+		case json.Number:
+			i, _ := n.Int64()
+			return i
+		}
+		m[key] = v // Not a number, keep it as is.
+		return 0
+	}
+	takeString := func(key string) string {
+		if s, ok := m[key].(string); ok {
+			delete(m, key)
+			return s
+		}
+		return ""
+	}
+	takeBool := func(key string) *bool {
+		if b, ok := m[key].(bool); ok {
+			delete(m, key)
+			return &b
+		}
+		return nil
+	}
+	c.Timeout = time.Duration(takeInt64("timeout")) * time.Millisecond
+	if _, ok := m["priority"]; ok {
+		c.Priority = Int(int(takeInt64("priority")))
+	}
+	c.Lane = takeString("lane")
+	c.QueryId = takeString("queryId")
+	c.SqlQueryId = takeString("sqlQueryId")
+	c.UseCache = takeBool("useCache")
+	c.PopulateCache = takeBool("populateCache")
+	c.UseResultLevelCache = takeBool("useResultLevelCache")
+	c.PopulateResultLevelCache = takeBool("populateResultLevelCache")
+	c.BySegment = takeBool("bySegment")
+	c.Finalize = takeBool("finalize")
+	c.MaxScatterGatherBytes = takeInt64("maxScatterGatherBytes")
+	c.MaxQueuedBytes = takeInt64("maxQueuedBytes")
+	c.MinTopNThreshold = int(takeInt64("minTopNThreshold"))
+	c.SkipEmptyBuckets = takeBool("skipEmptyBuckets")
+	c.GrandTotal = takeBool("grandTotal")
+	c.GroupByStrategy = takeString("groupByStrategy")
+	c.ChunkPeriod = takeString("chunkPeriod")
+	c.Vectorize = takeString("vectorize")
+	c.VectorSize = int(takeInt64("vectorSize"))
+	c.UncoveredIntervalsLimit = int(takeInt64("uncoveredIntervalsLimit"))
+	c.SerializeDateTimeAsLong = takeBool("serializeDateTimeAsLong")
+	if len(m) > 0 {
+		c.Extra = m
+	}
+	return c
+}
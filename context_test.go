@@ -0,0 +1,62 @@
+package godruid
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestQueryContext(t *testing.T) {
+	Convey("TestQueryContext", t, func() {
+		ctx := &QueryContext{
+			Timeout:  30 * time.Second,
+			Priority: Int(0),
+			UseCache: Bool(false),
+		}
+		ctx.Set("myKey", "v")
+
+		data, err := json.Marshal(ctx)
+		So(err, ShouldEqual, nil)
+		So(string(data), ShouldEqual, `{"myKey":"v","priority":0,"timeout":30000,"useCache":false}`)
+
+		back := &QueryContext{}
+		So(json.Unmarshal(data, back), ShouldEqual, nil)
+		So(back.Timeout, ShouldEqual, 30*time.Second)
+		So(*back.Priority, ShouldEqual, 0)
+		So(*back.UseCache, ShouldEqual, false)
+		So(back.Extra, ShouldResemble, map[string]interface{}{"myKey": "v"})
+
+		defaults := &QueryContext{Priority: Int(10), Lane: "low", UseCache: Bool(true)}
+		merged := ctx.Merge(defaults)
+		So(*merged.Priority, ShouldEqual, 0)
+		So(*merged.UseCache, ShouldEqual, false)
+		So(merged.Lane, ShouldEqual, "low")
+		So(merged.Timeout, ShouldEqual, 30*time.Second)
+
+		var nilCtx *QueryContext
+		So(nilCtx.Merge(nil), ShouldBeNil)
+		So(nilCtx.Merge(defaults).Lane, ShouldEqual, "low")
+	})
+}
+
+func TestClientQueryContext(t *testing.T) {
+	Convey("TestClientQueryContext", t, func() {
+		client := Client{
+			DefaultContext: &QueryContext{Priority: Int(10), Timeout: time.Minute},
+			DataSourceContext: map[string]*QueryContext{
+				"raw_events": {Priority: Int(-1)},
+			},
+		}
+
+		ctx := client.queryContext(&QueryTopN{DataSource: "campaign"})
+		So(*ctx.Priority, ShouldEqual, 10)
+
+		ctx = client.queryContext(&QueryTopN{DataSource: "raw_events"})
+		So(*ctx.Priority, ShouldEqual, -1)
+		So(ctx.Timeout, ShouldEqual, time.Minute)
+
+		ctx = client.queryContext(&QueryTopN{DataSource: "raw_events", Context: &QueryContext{Priority: Int(5)}})
+		So(*ctx.Priority, ShouldEqual, 5)
+	})
+}
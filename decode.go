@@ -0,0 +1,295 @@
+package godruid
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Row is a result row decoded into T.
+type Row[T any] struct {
+	Timestamp time.Time
+	Value     T
+}
+
+// DecodeGroupBy decodes the events of the groupBy results into T, which is a struct.
+//
+// The output names of the dimensions, aggregations and post aggregations are mapped to the
+// fields by the `druid:"name"` tags, the fields without the tag are matched by their names
+// case insensitively, and the fields tagged with `druid:"-"` are skipped. A field named
+// "timestamp" gets the timestamp of the row if the event doesn't have one.
+//
+// The integers are converted without precision loss as long as they are decoded as
+// json.Number, and the overflows are reported as errors.
+func DecodeGroupBy[T any](q *QueryGroupBy) ([]Row[T], error) {
+	rows := make([]Row[T], 0, len(q.QueryResult))
+	for _, item := range q.QueryResult {
+		row, err := decodeRow[T](item.Timestamp, item.Event)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// DecodeTimeseries decodes the timeseries results into T, check DecodeGroupBy for the details.
+func DecodeTimeseries[T any](q *QueryTimeseries) ([]Row[T], error) {
+	rows := make([]Row[T], 0, len(q.QueryResult))
+	for _, item := range q.QueryResult {
+		row, err := decodeRow[T](item.Timestamp, item.Result)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// DecodeTopN decodes the topN results into T, check DecodeGroupBy for the details.
+// The entries of every time bucket are flattened, in the order of the results.
+func DecodeTopN[T any](q *QueryTopN) ([]Row[T], error) {
+	var rows []Row[T]
+	for _, item := range q.QueryResult {
+		for _, entry := range item.Result {
+			row, err := decodeRow[T](item.Timestamp, entry)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// DecodeSelect decodes the events of the select results into T, check DecodeGroupBy for the details.
+func DecodeSelect[T any](q *QuerySelect) ([]Row[T], error) {
+	rows := make([]Row[T], 0, len(q.QueryResult.Result.Events))
+	for _, event := range q.QueryResult.Result.Events {
+		timestamp, _ := event.Event["timestamp"].(string)
+		row, err := decodeRow[T](timestamp, event.Event)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func decodeRow[T any](timestamp string, event map[string]interface{}) (row Row[T], err error) {
+	if timestamp != "" {
+		if row.Timestamp, err = parseISOTime(timestamp); err != nil {
+			return
+		}
+	}
+	v := reflect.ValueOf(&row.Value).Elem()
+	if v.Kind() != reflect.Struct {
+		return row, fmt.Errorf("godruid: can't decode rows into %s, it should be a struct", v.Type())
+	}
+	for _, f := range structFields(v.Type()) {
+		value, ok := event[f.name]
+		if !ok {
+			for name, ev := range event {
+				if strings.EqualFold(name, f.name) {
+					value, ok = ev, true
+					break
+				}
+			}
+		}
+		if !ok && strings.EqualFold(f.name, "timestamp") && timestamp != "" {
+			value, ok = timestamp, true
+		}
+		if !ok {
+			continue
+		}
+		if err = setValue(v.Field(f.index), value); err != nil {
+			return row, fmt.Errorf("godruid: decoding %q into field %s: %v", f.name, f.field, err)
+		}
+	}
+	return row, nil
+}
+
+type decodeField struct {
+	index int
+	field string // The name of the struct field.
+	name  string // The output name in results.
+}
+
+var decodeFieldsCache sync.Map // reflect.Type => []decodeField
+
+func structFields(t reflect.Type) []decodeField {
+	if fields, ok := decodeFieldsCache.Load(t); ok {
+		return fields.([]decodeField)
+	}
+	var fields []decodeField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue // Unexported.
+		}
+		name := sf.Name
+		if tag, ok := sf.Tag.Lookup("druid"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, decodeField{index: i, field: sf.Name, name: name})
+	}
+	decodeFieldsCache.Store(t, fields)
+	return fields
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	numberType = reflect.TypeOf(json.Number(""))
+)
+
+// setValue sets the result value v into dst with the conversions checked.
+func setValue(dst reflect.Value, v interface{}) error {
+	if v == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	switch {
+	case dst.Type() == timeType:
+		t, err := toTime(v)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	case dst.Type() == numberType:
+		dst.SetString(numberString(v))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(dst.Type().Elem())
+		if err := setValue(elem.Elem(), v); err != nil {
+			return err
+		}
+		dst.Set(elem)
+	case reflect.Interface:
+		rv := reflect.ValueOf(v)
+		if !rv.Type().AssignableTo(dst.Type()) {
+			return fmt.Errorf("%T is not assignable to %s", v, dst.Type())
+		}
+		dst.Set(rv)
+	case reflect.String:
+		if s, ok := v.(string); ok {
+			dst.SetString(s)
+		} else {
+			dst.SetString(numberString(v))
+		}
+	case reflect.Bool:
+		switch b := v.(type) {
+		case bool:
+			dst.SetBool(b)
+		case string:
+			parsed, err := strconv.ParseBool(b)
+			if err != nil {
+				return err
+			}
+			dst.SetBool(parsed)
+		default:
+			return fmt.Errorf("%v is not a bool", v)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := toExactInt64(v)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowInt(i) {
+			return fmt.Errorf("%d overflows %s", i, dst.Type())
+		}
+		dst.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(numberString(v), 10, 64)
+		if err != nil {
+			i, ierr := toExactInt64(v)
+			if ierr != nil || i < 0 {
+				return fmt.Errorf("%v is not an unsigned integer", v)
+			}
+			u = uint64(i)
+		}
+		if dst.OverflowUint(u) {
+			return fmt.Errorf("%d overflows %s", u, dst.Type())
+		}
+		dst.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat64(v)
+		if !ok {
+			s, isString := v.(string)
+			if !isString {
+				return fmt.Errorf("%v is not a number", v)
+			}
+			var err error
+			if f, err = strconv.ParseFloat(s, 64); err != nil {
+				return err
+			}
+		}
+		if dst.OverflowFloat(f) {
+			return fmt.Errorf("%v overflows %s", f, dst.Type())
+		}
+		dst.SetFloat(f)
+	default:
+		// Let encoding/json handle the complex values like sketches.
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, dst.Addr().Interface())
+	}
+	return nil
+}
+
+// toExactInt64 converts v to int64, the floats must be integral and in range.
+func toExactInt64(v interface{}) (int64, error) {
+	if i, ok := toInt64(v); ok {
+		return i, nil
+	}
+	if s, ok := v.(string); ok {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	f, ok := toFloat64(v)
+	if !ok {
+		return 0, fmt.Errorf("%v is not a number", v)
+	}
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, fmt.Errorf("%v is not an int64", v)
+	}
+	return int64(f), nil
+}
+
+func numberString(v interface{}) string {
+	switch n := v.(type) {
+	case json.Number:
+		return string(n)
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	case string:
+		return n
+	}
+	return fmt.Sprint(v)
+}
+
+// toTime converts the ISO-8601 strings or the milliseconds since epoch to time.
+func toTime(v interface{}) (time.Time, error) {
+	if s, ok := v.(string); ok {
+		return parseISOTime(s)
+	}
+	ms, err := toExactInt64(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC(), nil
+}
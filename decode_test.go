@@ -0,0 +1,78 @@
+package godruid
+
+import (
+	"encoding/json"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestDecodeGroupBy(t *testing.T) {
+	Convey("TestDecodeGroupBy", t, func() {
+		type stats struct {
+			Day     time.Time `druid:"timestamp"`
+			Country string    `druid:"country"`
+			Bytes   int64     `druid:"bytes"`
+			Count   int
+			Ratio   *float64 `druid:"ratio"`
+			Skipped string   `druid:"-"`
+		}
+		query := &QueryGroupBy{
+			QueryResult: []GroupbyItem{{
+				Timestamp: "2015-01-01T00:00:00.000Z",
+				Event: map[string]interface{}{
+					"country": "cn",
+					"bytes":   json.Number("9007199254740993"),
+					"count":   2.0,
+					"ratio":   json.Number("0.5"),
+					"Skipped": "x",
+				},
+			}},
+		}
+		rows, err := DecodeGroupBy[stats](query)
+		So(err, ShouldEqual, nil)
+		So(len(rows), ShouldEqual, 1)
+		day := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+		So(rows[0].Timestamp, ShouldResemble, day)
+		So(rows[0].Value.Day, ShouldResemble, day)
+		So(rows[0].Value.Country, ShouldEqual, "cn")
+		So(rows[0].Value.Bytes, ShouldEqual, int64(9007199254740993))
+		So(rows[0].Value.Count, ShouldEqual, 2)
+		So(*rows[0].Value.Ratio, ShouldEqual, 0.5)
+		So(rows[0].Value.Skipped, ShouldEqual, "")
+
+		query.QueryResult[0].Event["count"] = 2.5
+		_, err = DecodeGroupBy[stats](query)
+		So(err, ShouldNotEqual, nil)
+
+		_, err = DecodeGroupBy[int](query)
+		So(err, ShouldNotEqual, nil)
+	})
+}
+
+func TestEventAccessors(t *testing.T) {
+	Convey("TestEventAccessors", t, func() {
+		query := &QueryTimeseries{}
+		err := query.onResponse([]byte(`[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"bytes":9007199254740993,"ctr":0.25,"country":"cn"}}]`))
+		So(err, ShouldEqual, nil)
+		event := query.QueryResult[0].Result
+
+		bytes, err := event.Int64("bytes")
+		So(err, ShouldEqual, nil)
+		So(bytes, ShouldEqual, int64(9007199254740993))
+		ctr, err := event.Float64("ctr")
+		So(err, ShouldEqual, nil)
+		So(ctr, ShouldEqual, 0.25)
+		country, err := event.String("country")
+		So(err, ShouldEqual, nil)
+		So(country, ShouldEqual, "cn")
+
+		_, err = event.Int64("ctr")
+		So(err, ShouldNotEqual, nil)
+		_, err = event.String("bytes")
+		So(err, ShouldNotEqual, nil)
+		_, err = event.Float64("missing")
+		So(errors.Is(err, ErrMissingField), ShouldBeTrue)
+	})
+}
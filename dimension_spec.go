@@ -1,5 +1,9 @@
 package godruid
 
+import (
+	"fmt"
+)
+
 type DimSpec interface{}
 
 type Dimension struct {
@@ -18,6 +22,22 @@ type DimExtractionFn struct {
 	Function     string       `json:"function,omitempty"`
 }
 
+// dimOutputName returns the name of the dimension in the results.
+func dimOutputName(dim DimSpec) string {
+	switch d := dim.(type) {
+	case string:
+		return d
+	case *Dimension:
+		if d.OutputName != "" {
+			return d.OutputName
+		}
+		return d.Dimension
+	case Dimension:
+		return dimOutputName(&d)
+	}
+	return fmt.Sprint(dim)
+}
+
 func DimDefault(dimension, outputName string) DimSpec {
 	return &Dimension{
 		Type:       "default",
@@ -0,0 +1,212 @@
+// Package druidarrow writes the godruid query results as Apache Arrow IPC streams.
+package druidarrow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	"github.com/shunfei/godruid"
+)
+
+// DefaultBatchSize is the rows of a record batch if not specified.
+const DefaultBatchSize = 4096
+
+// Writer writes the rows as an Arrow IPC stream, a record batch for every BatchSize rows.
+// It implements godruid.TableWriter.
+//
+// The column types are derived from the query: timestamps are timestamp[ms, UTC],
+// dimensions are strings, and the aggregations are int64, float32 or float64 by their
+// types. The columns of unknown types are written as strings, with the complex values
+// encoded as JSON.
+type Writer struct {
+	BatchSize int
+	Allocator memory.Allocator
+
+	out     io.Writer
+	w       *ipc.Writer
+	builder *array.RecordBuilder
+	rows    int
+}
+
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out, BatchSize: DefaultBatchSize, Allocator: memory.DefaultAllocator}
+}
+
+// Schema returns the Arrow schema of the columns.
+func Schema(columns []godruid.TableColumn) *arrow.Schema {
+	fields := make([]arrow.Field, len(columns))
+	for i, c := range columns {
+		fields[i] = arrow.Field{Name: c.Name, Type: arrowType(c.Type), Nullable: true}
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+func arrowType(t godruid.ValueType) arrow.DataType {
+	switch t {
+	case godruid.ValueTimestamp:
+		return arrow.FixedWidthTypes.Timestamp_ms
+	case godruid.ValueLong:
+		return arrow.PrimitiveTypes.Int64
+	case godruid.ValueFloat:
+		return arrow.PrimitiveTypes.Float32
+	case godruid.ValueDouble:
+		return arrow.PrimitiveTypes.Float64
+	}
+	return arrow.BinaryTypes.String
+}
+
+func (w *Writer) WriteHeader(columns []godruid.TableColumn) error {
+	schema := Schema(columns)
+	w.w = ipc.NewWriter(w.out, ipc.WithSchema(schema), ipc.WithAllocator(w.Allocator))
+	w.builder = array.NewRecordBuilder(w.Allocator, schema)
+	return nil
+}
+
+func (w *Writer) WriteRow(row []interface{}) error {
+	if w.builder == nil {
+		return fmt.Errorf("druidarrow: WriteRow called before WriteHeader")
+	}
+	if n := len(w.builder.Fields()); len(row) != n {
+		return fmt.Errorf("druidarrow: row of %d values for %d columns", len(row), n)
+	}
+	for i, v := range row {
+		if err := appendValue(w.builder.Field(i), v); err != nil {
+			return fmt.Errorf("druidarrow: column %q: %v", w.builder.Schema().Field(i).Name, err)
+		}
+	}
+	w.rows++
+	if w.rows >= w.BatchSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes the buffered rows and the end of the stream.
+func (w *Writer) Close() error {
+	if w.w == nil {
+		return nil
+	}
+	defer w.builder.Release()
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.w.Close()
+}
+
+func (w *Writer) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	rec := w.builder.NewRecord()
+	defer rec.Release()
+	w.rows = 0
+	return w.w.Write(rec)
+}
+
+func appendValue(b array.Builder, v interface{}) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+	switch b := b.(type) {
+	case *array.TimestampBuilder:
+		t, ok := v.(time.Time)
+		if !ok {
+			return fmt.Errorf("%v is not a time", v)
+		}
+		b.Append(arrow.Timestamp(t.UnixMilli()))
+	case *array.Int64Builder:
+		i, err := toInt64(v)
+		if err != nil {
+			return err
+		}
+		b.Append(i)
+	case *array.Float32Builder:
+		f, err := toFloat64(v)
+		if err != nil {
+			return err
+		}
+		b.Append(float32(f))
+	case *array.Float64Builder:
+		f, err := toFloat64(v)
+		if err != nil {
+			return err
+		}
+		b.Append(f)
+	case *array.StringBuilder:
+		s, err := toString(v)
+		if err != nil {
+			return err
+		}
+		b.Append(s)
+	default:
+		return fmt.Errorf("unsupported builder %T", b)
+	}
+	return nil
+}
+
+// toInt64 converts v to int64, the values with fractions or out of the range of int64
+// are errors rather than truncated.
+func toInt64(v interface{}) (int64, error) {
+	var f float64
+	switch n := v.(type) {
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		var err error
+		if f, err = n.Float64(); err != nil {
+			return 0, err
+		}
+	case int64:
+		return n, nil
+	case int:
+		return int64(n), nil
+	case float64:
+		f = n
+	default:
+		return 0, fmt.Errorf("%v is not a number", v)
+	}
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, fmt.Errorf("%v is not an int64", v)
+	}
+	return int64(f), nil
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.Float64()
+	case float64:
+		return n, nil
+	case int64:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+func toString(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case json.Number:
+		return string(s), nil
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), nil
+	case time.Time:
+		return s.Format(godruid.IntervalTimeFormat), nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
@@ -0,0 +1,57 @@
+package druidarrow
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/shunfei/godruid"
+)
+
+func TestWriter(t *testing.T) {
+	Convey("TestWriter", t, func() {
+		query := &godruid.QueryGroupBy{
+			Dimensions:   []godruid.DimSpec{"country"},
+			Aggregations: []godruid.Aggregation{godruid.AggLongSum("bytes", "bytes")},
+			QueryResult: []godruid.GroupbyItem{
+				{Timestamp: "2015-01-01T00:00:00.000Z", Event: godruid.Event{"country": "cn", "bytes": json.Number("9007199254740993")}},
+				{Timestamp: "2015-01-01T00:00:00.000Z", Event: godruid.Event{"country": nil, "bytes": json.Number("1")}},
+				{Timestamp: "2015-01-02T00:00:00.000Z", Event: godruid.Event{"country": "us", "bytes": json.Number("2")}},
+			},
+		}
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.BatchSize = 2
+		So(godruid.WriteQuery(w, query), ShouldEqual, nil)
+
+		r, err := ipc.NewReader(&buf)
+		So(err, ShouldEqual, nil)
+		defer r.Release()
+		So(r.Schema().String(), ShouldContainSubstring, "bytes: type=int64")
+
+		var batches, rows int
+		for r.Next() {
+			rec := r.Record()
+			if batches == 0 {
+				So(rec.Column(2).(*array.Int64).Value(0), ShouldEqual, int64(9007199254740993))
+				So(rec.Column(1).IsNull(1), ShouldBeTrue)
+			}
+			batches++
+			rows += int(rec.NumRows())
+		}
+		So(batches, ShouldEqual, 2)
+		So(rows, ShouldEqual, 3)
+
+		w = NewWriter(&bytes.Buffer{})
+		So(w.WriteRow([]interface{}{1}), ShouldNotEqual, nil)
+		So(w.WriteHeader([]godruid.TableColumn{{Name: "bytes", Type: godruid.ValueLong}}), ShouldEqual, nil)
+		So(w.WriteRow([]interface{}{2.0}), ShouldEqual, nil)
+		So(w.WriteRow([]interface{}{2.5}), ShouldNotEqual, nil)
+		So(w.WriteRow([]interface{}{json.Number("1.5")}), ShouldNotEqual, nil)
+		So(w.Close(), ShouldEqual, nil)
+	})
+}
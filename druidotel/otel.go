@@ -0,0 +1,142 @@
+// Package druidotel traces the godruid queries with OpenTelemetry.
+package druidotel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/shunfei/godruid"
+)
+
+const instrumentationName = "github.com/shunfei/godruid/druidotel"
+
+type config struct {
+	provider    trace.TracerProvider
+	propagators propagation.TextMapPropagator
+}
+
+type Option func(*config)
+
+// WithTracerProvider sets the TracerProvider, the global one by default.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) { c.provider = provider }
+}
+
+// WithPropagators sets the propagators of the trace context headers, the global ones by default.
+func WithPropagators(propagators propagation.TextMapPropagator) Option {
+	return func(c *config) { c.propagators = propagators }
+}
+
+// Instrument sets up c to send every query in a span, with an Interceptor in front of the
+// Interceptors of c, so the queries sent by QuerySplit and QueryIncremental are traced too.
+// It also sets up the Transport of c to send the trace context headers to the broker, and
+// the Tracer of c to record the details of the requests on the spans, so c should not be
+// used concurrently while it's being instrumented. The Transport or TLSConfig of c should
+// be set before, as the base transport of c is wrapped.
+func Instrument(c *godruid.Client, opts ...Option) {
+	cfg := config{provider: otel.GetTracerProvider(), propagators: otel.GetTextMapPropagator()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	c.Transport = &transport{base: c.BaseTransport(), propagators: cfg.propagators}
+	c.Tracer = godruid.MultiTracer(c.Tracer, godruid.TracerFunc(recordResponse))
+	interceptor := spanInterceptor(cfg.provider.Tracer(instrumentationName))
+	c.Interceptors = append([]godruid.Interceptor{interceptor}, c.Interceptors...)
+}
+
+// spanInterceptor sends the queries in the spans with the attributes of the queries and
+// the number of the rows of the results, e.g. the entries of all the buckets of topN
+// queries. The spans of the queries decoded by the client end after the decoding.
+func spanInterceptor(tracer trace.Tracer) godruid.Interceptor {
+	return func(next godruid.QueryFunc) godruid.QueryFunc {
+		return func(ctx context.Context, query godruid.Query, req []byte) ([]byte, error) {
+			ctx, span := tracer.Start(ctx, "druid.query",
+				trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(queryAttributes(req)...))
+			result, err := next(ctx, query, req)
+			end := func(query godruid.Query, err error) {
+				if err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+				} else if query != nil {
+					span.SetAttributes(attribute.Int("druid.result.rows", godruid.ResultRows(query)))
+				}
+				span.End()
+			}
+			if err != nil || !godruid.AfterDecode(ctx, end) {
+				end(nil, err)
+			}
+			return result, err
+		}
+	}
+}
+
+// queryAttributes returns the attributes of the query JSON.
+func queryAttributes(req []byte) []attribute.KeyValue {
+	var q struct {
+		QueryType   string          `json:"queryType"`
+		DataSource  json.RawMessage `json:"dataSource"`
+		Intervals   []string        `json:"intervals"`
+		Granularity json.RawMessage `json:"granularity"`
+		Context     struct {
+			QueryId string `json:"queryId"`
+		} `json:"context"`
+	}
+	json.Unmarshal(req, &q)
+	return []attribute.KeyValue{
+		attribute.String("db.system", "druid"),
+		attribute.String("druid.query.type", q.QueryType),
+		attribute.String("druid.query.id", q.Context.QueryId),
+		attribute.String("druid.datasource", unquote(q.DataSource)),
+		attribute.StringSlice("druid.intervals", q.Intervals),
+		attribute.String("druid.granularity", unquote(q.Granularity)),
+	}
+}
+
+// unquote returns the JSON string, or the JSON itself if it's not a string.
+func unquote(data json.RawMessage) string {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		return s
+	}
+	return string(data)
+}
+
+// recordResponse records the response of the broker on the span in ctx.
+func recordResponse(ctx context.Context, t *godruid.Trace) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		attribute.String("server.address", t.URL),
+		attribute.Int("http.response.status_code", t.Status),
+		attribute.Int("druid.response.bytes", len(t.Response)),
+	)
+	var druidErr *godruid.DruidError
+	if errors.As(t.Err, &druidErr) {
+		span.SetAttributes(
+			attribute.String("druid.error.code", druidErr.ErrorCode),
+			attribute.String("druid.error.message", druidErr.ErrorMessage),
+			attribute.String("druid.error.class", druidErr.ErrorClass),
+			attribute.String("druid.error.host", druidErr.Host),
+		)
+	}
+}
+
+// transport injects the trace context of the requests into their headers.
+type transport struct {
+	base        http.RoundTripper
+	propagators propagation.TextMapPropagator
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request.
+	req = req.Clone(req.Context())
+	t.propagators.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	return t.base.RoundTrip(req)
+}
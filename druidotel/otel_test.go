@@ -0,0 +1,120 @@
+package druidotel
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/shunfei/godruid"
+)
+
+func TestInstrument(t *testing.T) {
+	Convey("TestInstrument", t, func() {
+		var traceparent string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get("traceparent")
+			if r.URL.Query().Get("fail") != "" {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"error":"Query timeout","errorMessage":"timed out","host":"h1"}`))
+				return
+			}
+			w.Write([]byte(`[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":3}}]`))
+		}))
+		defer server.Close()
+
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		client := &godruid.Client{Url: server.URL}
+		Instrument(client, WithTracerProvider(provider), WithPropagators(propagation.TraceContext{}))
+		query := &godruid.QueryTimeseries{
+			DataSource:   "events",
+			Intervals:    []string{"2015-01-01/2015-01-02"},
+			Granularity:  godruid.GranDay,
+			Aggregations: []godruid.Aggregation{godruid.AggCount("count")},
+			Context:      &godruid.QueryContext{QueryId: "q1"},
+		}
+		So(client.Query(query), ShouldEqual, nil)
+
+		spans := exporter.GetSpans()
+		So(len(spans), ShouldEqual, 1)
+		span := spans[0]
+		So(traceparent, ShouldContainSubstring, span.SpanContext.TraceID().String())
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes {
+			attrs[kv.Key] = kv.Value
+		}
+		So(attrs["druid.query.type"].AsString(), ShouldEqual, "timeseries")
+		So(attrs["druid.datasource"].AsString(), ShouldEqual, "events")
+		So(attrs["druid.granularity"].AsString(), ShouldEqual, "day")
+		So(attrs["druid.query.id"].AsString(), ShouldEqual, "q1")
+		So(attrs["druid.result.rows"].AsInt64(), ShouldEqual, 1)
+		So(attrs["server.address"].AsString(), ShouldEqual, server.URL+godruid.DefaultEndPoint)
+		So(attrs["http.response.status_code"].AsInt64(), ShouldEqual, 200)
+
+		exporter.Reset()
+		client.EndPoint = godruid.DefaultEndPoint + "?fail=1"
+		So(client.Query(query), ShouldNotEqual, nil)
+		span = exporter.GetSpans()[0]
+		So(span.Status.Code, ShouldEqual, codes.Error)
+		found := false
+		for _, kv := range span.Attributes {
+			if kv.Key == "druid.error.code" {
+				found = kv.Value.AsString() == "Query timeout"
+			}
+		}
+		So(found, ShouldBeTrue)
+
+		// The queries sent without QueryWithContext are traced too.
+		exporter.Reset()
+		client.EndPoint = ""
+		query.Intervals = []string{"2015-01-01/2015-01-03"}
+		So(client.QuerySplit(context.Background(), query, godruid.SplitSpec{Period: godruid.MustParsePeriod("P1D")}), ShouldEqual, nil)
+		So(len(exporter.GetSpans()), ShouldEqual, 2)
+
+		Convey("the rows of topN and decode errors", func() {
+			topNServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[{"timestamp":"2015-01-01T00:00:00.000Z","result":[{"country":"cn","count":2},{"country":"us","count":1}]}]`))
+			}))
+			defer topNServer.Close()
+			client := &godruid.Client{Url: topNServer.URL}
+			Instrument(client, WithTracerProvider(provider), WithPropagators(propagation.TraceContext{}))
+			exporter.Reset()
+			topN := &godruid.QueryTopN{
+				DataSource:   "events",
+				Intervals:    []string{"2015-01-01/2015-01-02"},
+				Granularity:  godruid.GranAll,
+				Dimension:    "country",
+				Threshold:    2,
+				Metric:       godruid.TopNMetricNumeric("count"),
+				Aggregations: []godruid.Aggregation{godruid.AggCount("count")},
+			}
+			So(client.Query(topN), ShouldEqual, nil)
+			So(exporter.GetSpans()[0].Attributes, ShouldContain, attribute.Int("druid.result.rows", 2))
+
+			// The response of topN is not of timeseries.
+			exporter.Reset()
+			So(client.Query(query), ShouldNotEqual, nil)
+			So(exporter.GetSpans()[0].Status.Code, ShouldEqual, codes.Error)
+		})
+
+		Convey("TLSConfig", func() {
+			tlsServer := httptest.NewTLSServer(server.Config.Handler)
+			defer tlsServer.Close()
+			roots := x509.NewCertPool()
+			roots.AddCert(tlsServer.Certificate())
+			client := &godruid.Client{Url: tlsServer.URL, TLSConfig: &tls.Config{RootCAs: roots}}
+			Instrument(client, WithTracerProvider(provider), WithPropagators(propagation.TraceContext{}))
+			So(client.Query(query), ShouldEqual, nil)
+		})
+	})
+}
@@ -0,0 +1,122 @@
+// Package druidprom exposes the metrics of the godruid queries to Prometheus.
+package druidprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/shunfei/godruid"
+)
+
+var labelNames = []string{"query_type", "datasource", "broker"}
+
+// Recorder is a godruid.MetricsRecorder, a godruid.QueueWaitRecorder, a godruid.RetryRecorder
+// and a prometheus.Collector, register it and set it as the Metrics of the Client:
+//
+//	recorder := druidprom.NewRecorder("myapp")
+//	prometheus.MustRegister(recorder)
+//	client.Metrics = recorder
+type Recorder struct {
+	queries       *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	responseBytes *prometheus.HistogramVec
+	errors        *prometheus.CounterVec
+	retries       *prometheus.CounterVec
+	cache         *prometheus.CounterVec
+	inFlight      *prometheus.GaugeVec
+	queueWait     *prometheus.HistogramVec
+}
+
+// NewRecorder returns the Recorder with the metrics named namespace_druid_*.
+func NewRecorder(namespace string) *Recorder {
+	return &Recorder{
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "druid", Name: "queries_total",
+			Help: "The number of the queries sent to the broker.",
+		}, labelNames),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "druid", Name: "query_duration_seconds",
+			Help:    "The latency of the queries.",
+			Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		}, labelNames),
+		responseBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "druid", Name: "response_bytes",
+			Help:    "The size of the responses.",
+			Buckets: prometheus.ExponentialBuckets(256, 4, 10),
+		}, labelNames),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "druid", Name: "query_errors_total",
+			Help: "The number of the failed queries by the error class.",
+		}, append(labelNames, "error_class")),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "druid", Name: "query_retries_total",
+			Help: "The number of the queries retried.",
+		}, labelNames),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "druid", Name: "cache_lookups_total",
+			Help: "The number of the cache lookups by the result, hit or miss.",
+		}, append(labelNames, "result")),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "druid", Name: "queries_in_flight",
+			Help: "The number of the queries waiting for the broker.",
+		}, labelNames),
+		queueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "druid", Name: "queue_wait_seconds",
+			Help:    "How long the queries wait for the limits of the Limiter.",
+			Buckets: []float64{.001, .01, .05, .1, .5, 1, 5, 10, 30},
+		}, labelNames),
+	}
+}
+
+func (r *Recorder) QueryStarted(labels godruid.MetricLabels) {
+	r.inFlight.WithLabelValues(values(labels)...).Inc()
+}
+
+func (r *Recorder) QueryDone(labels godruid.MetricLabels, duration time.Duration, responseBytes int, err error) {
+	lv := values(labels)
+	r.inFlight.WithLabelValues(lv...).Dec()
+	r.queries.WithLabelValues(lv...).Inc()
+	r.duration.WithLabelValues(lv...).Observe(duration.Seconds())
+	if err != nil {
+		r.errors.WithLabelValues(append(lv, godruid.ErrorClass(err))...).Inc()
+		return
+	}
+	r.responseBytes.WithLabelValues(lv...).Observe(float64(responseBytes))
+}
+
+func (r *Recorder) QueryRetried(labels godruid.MetricLabels) {
+	r.retries.WithLabelValues(values(labels)...).Inc()
+}
+
+func (r *Recorder) CacheLookup(labels godruid.MetricLabels, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	r.cache.WithLabelValues(append(values(labels), result)...).Inc()
+}
+
+func (r *Recorder) QueueWait(labels godruid.MetricLabels, wait time.Duration) {
+	r.queueWait.WithLabelValues(values(labels)...).Observe(wait.Seconds())
+}
+
+func (r *Recorder) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range r.collectors() {
+		c.Describe(ch)
+	}
+}
+
+func (r *Recorder) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range r.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (r *Recorder) collectors() []prometheus.Collector {
+	return []prometheus.Collector{r.queries, r.duration, r.responseBytes, r.errors, r.retries, r.cache, r.inFlight, r.queueWait}
+}
+
+func values(labels godruid.MetricLabels) []string {
+	return []string{labels.QueryType, labels.DataSource, labels.Broker}
+}
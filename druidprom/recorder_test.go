@@ -0,0 +1,85 @@
+package druidprom
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/shunfei/godruid"
+)
+
+func TestRecorder(t *testing.T) {
+	Convey("TestRecorder", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":3}}]`))
+		}))
+		defer server.Close()
+
+		recorder := NewRecorder("test")
+		registry := prometheus.NewPedanticRegistry()
+		So(registry.Register(recorder), ShouldEqual, nil)
+
+		client := godruid.Client{Url: server.URL, Metrics: recorder, Cache: godruid.NewLRUCache(1 << 20)}
+		for i := 0; i < 2; i++ {
+			query := &godruid.QueryTimeseries{
+				DataSource:   "events",
+				Intervals:    []string{"2015-01-01/2015-01-02"},
+				Granularity:  godruid.GranAll,
+				Aggregations: []godruid.Aggregation{godruid.AggCount("count")},
+			}
+			So(client.Query(query), ShouldEqual, nil)
+		}
+
+		lv := []string{"timeseries", "events", server.URL}
+		So(testutil.ToFloat64(recorder.queries.WithLabelValues(lv...)), ShouldEqual, 1)
+		So(testutil.ToFloat64(recorder.inFlight.WithLabelValues(lv...)), ShouldEqual, 0)
+		So(testutil.ToFloat64(recorder.cache.WithLabelValues(append(lv, "hit")...)), ShouldEqual, 1)
+		So(testutil.ToFloat64(recorder.cache.WithLabelValues(append(lv, "miss")...)), ShouldEqual, 1)
+		So(testutil.CollectAndCount(recorder, "test_druid_query_duration_seconds"), ShouldEqual, 1)
+
+		retryOnce := func(next godruid.QueryFunc) godruid.QueryFunc {
+			return func(ctx context.Context, query godruid.Query, req []byte) ([]byte, error) {
+				next(ctx, query, req)
+				return next(godruid.NextAttempt(ctx), query, req)
+			}
+		}
+		client = godruid.Client{Url: server.URL, Metrics: recorder, Interceptors: []godruid.Interceptor{retryOnce}}
+		query := &godruid.QueryTimeseries{
+			DataSource:   "events",
+			Intervals:    []string{"2015-01-01/2015-01-02"},
+			Granularity:  godruid.GranAll,
+			Aggregations: []godruid.Aggregation{godruid.AggCount("count")},
+		}
+		So(client.Query(query), ShouldEqual, nil)
+		So(testutil.ToFloat64(recorder.queries.WithLabelValues(lv...)), ShouldEqual, 3)
+		So(testutil.ToFloat64(recorder.retries.WithLabelValues(lv...)), ShouldEqual, 1)
+
+		Convey("failed queries", func() {
+			badServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"not":"array"}`))
+			}))
+			defer badServer.Close()
+			client := godruid.Client{
+				Url:     badServer.URL,
+				Metrics: recorder,
+				Limiter: godruid.NewLimiter(godruid.LimiterConfig{Global: godruid.QueryLimits{Rate: 1, Burst: 1}}),
+			}
+			So(client.Query(query), ShouldNotEqual, nil)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			So(client.QueryWithContext(ctx, query), ShouldNotEqual, nil)
+
+			lv := []string{"timeseries", "events", badServer.URL}
+			So(testutil.ToFloat64(recorder.queries.WithLabelValues(lv...)), ShouldEqual, 2)
+			So(testutil.ToFloat64(recorder.inFlight.WithLabelValues(lv...)), ShouldEqual, 0)
+			So(testutil.ToFloat64(recorder.errors.WithLabelValues(append(lv, "decode")...)), ShouldEqual, 1)
+			So(testutil.ToFloat64(recorder.errors.WithLabelValues(append(lv, "rate_limited")...)), ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,74 @@
+package godruid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// DruidError is a non-200 response of the broker. The fields besides the status are
+// parsed from the error body of Druid, and are empty if the body is not such one, e.g.
+// the error page of a proxy.
+type DruidError struct {
+	StatusCode   int
+	Status       string
+	ErrorCode    string `json:"error"` // e.g. "Query timeout", "Resource limit exceeded".
+	ErrorMessage string `json:"errorMessage"`
+	ErrorClass   string `json:"errorClass"` // The Java exception class.
+	Host         string `json:"host"`
+	Body         []byte `json:"-"`
+}
+
+func (e *DruidError) Error() string {
+	body := e.Body
+	if isSmile(body) {
+		body = smileText(body)
+	}
+	return fmt.Sprintf("%s: %s", e.Status, string(body))
+}
+
+func newDruidError(resp *http.Response, body []byte) *DruidError {
+	e := &DruidError{}
+	unmarshalResponse(body, e)
+	e.StatusCode = resp.StatusCode
+	e.Status = resp.Status
+	e.Body = body
+	return e
+}
+
+// ErrorClass returns a short class of err for logs and metrics: "canceled", "timeout",
+// "rate_limited" for the queries rejected by Limiter, "incomplete" for IncompleteResultError,
+// "decode" for the invalid JSON or Smile responses, the error code of Druid, "http_<status>" for the
+// other error responses, and "network" for the rest.
+func ErrorClass(err error) string {
+	var druidErr *DruidError
+	var incompleteErr *IncompleteResultError
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &incompleteErr):
+		return "incomplete"
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, errInvalidSmile):
+		return "decode"
+	case errors.As(err, &druidErr):
+		if druidErr.ErrorCode != "" {
+			return druidErr.ErrorCode
+		}
+		return fmt.Sprintf("http_%d", druidErr.StatusCode)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	}
+	return "network"
+}
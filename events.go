@@ -0,0 +1,93 @@
+package godruid
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Event is a row of the query results, keyed by the output names of the dimensions,
+// aggregations and post aggregations. The numbers are json.Number, use the accessors
+// to get them with the conversions checked.
+type Event map[string]interface{}
+
+// ErrMissingField is returned by the accessors of Event if the field is not in the event.
+var ErrMissingField = errors.New("godruid: missing field")
+
+func (e Event) get(name string) (interface{}, error) {
+	v, ok := e[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrMissingField, name)
+	}
+	return v, nil
+}
+
+// Int64 returns the field as int64, it fails if the field is not an integer or out of range.
+func (e Event) Int64(name string) (int64, error) {
+	v, err := e.get(name)
+	if err != nil {
+		return 0, err
+	}
+	i, err := toExactInt64(v)
+	if err != nil {
+		return 0, fmt.Errorf("godruid: field %q: %v", name, err)
+	}
+	return i, nil
+}
+
+// Float64 returns the field as float64, it fails if the field is not a number.
+func (e Event) Float64(name string) (float64, error) {
+	v, err := e.get(name)
+	if err != nil {
+		return 0, err
+	}
+	if f, ok := toFloat64(v); ok {
+		return f, nil
+	}
+	if s, ok := v.(string); ok {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("godruid: field %q: %v is not a number", name, v)
+}
+
+// String returns the field as string, it fails if the field is not a string.
+func (e Event) String(name string) (string, error) {
+	v, err := e.get(name)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("godruid: field %q: %v is not a string", name, v)
+	}
+	return s, nil
+}
+
+// Bool returns the field as bool, it fails if the field is not a bool.
+func (e Event) Bool(name string) (bool, error) {
+	v, err := e.get(name)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("godruid: field %q: %v is not a bool", name, v)
+	}
+	return b, nil
+}
+
+// Time returns the field as time, which is an ISO-8601 string or milliseconds since epoch.
+func (e Event) Time(name string) (time.Time, error) {
+	v, err := e.get(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := toTime(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("godruid: field %q: %v", name, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,185 @@
+package godruid
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// TableWriter writes ResultTable rows incrementally: WriteHeader once, WriteRow for
+// every row and Close at last. Close flushes the buffered data but doesn't close the
+// underlying io.Writer.
+type TableWriter interface {
+	WriteHeader(columns []TableColumn) error
+	WriteRow(row []interface{}) error
+	Close() error
+}
+
+// WriteTable writes the whole table with w and closes w.
+func WriteTable(w TableWriter, t *ResultTable) error {
+	if err := w.WriteHeader(t.Columns); err != nil {
+		return err
+	}
+	if err := t.Each(w.WriteRow); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// WriteQuery writes the results of query with w and closes w.
+func WriteQuery(w TableWriter, query Query) error {
+	t, err := query.Table()
+	if err != nil {
+		return err
+	}
+	return WriteTable(w, t)
+}
+
+// ---------------------------------
+// CSV
+// ---------------------------------
+
+type CSVOptions struct {
+	Comma      rune   // The field delimiter, ',' if 0.
+	NoHeader   bool   // Don't write the column names as the first line.
+	Null       string // The text of the null values, empty by default.
+	TimeFormat string // The layout of the time values, IntervalTimeFormat if empty.
+}
+
+type CSVWriter struct {
+	w    *csv.Writer
+	opts CSVOptions
+}
+
+func NewCSVWriter(w io.Writer, opts CSVOptions) *CSVWriter {
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = IntervalTimeFormat
+	}
+	return &CSVWriter{w: cw, opts: opts}
+}
+
+func (w *CSVWriter) WriteHeader(columns []TableColumn) error {
+	if w.opts.NoHeader {
+		return nil
+	}
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return w.w.Write(names)
+}
+
+func (w *CSVWriter) WriteRow(row []interface{}) error {
+	record := make([]string, len(row))
+	for i, v := range row {
+		if v == nil {
+			record[i] = w.opts.Null
+			continue
+		}
+		s, err := formatValue(v, w.opts.TimeFormat)
+		if err != nil {
+			return err
+		}
+		record[i] = s
+	}
+	return w.w.Write(record)
+}
+
+func (w *CSVWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// formatValue formats a result value as text, the complex values are formatted as JSON.
+func formatValue(v interface{}, timeFormat string) (string, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case json.Number:
+		return string(x), nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	case int64:
+		return strconv.FormatInt(x, 10), nil
+	case int:
+		return strconv.Itoa(x), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case time.Time:
+		return x.Format(timeFormat), nil
+	case map[string]interface{}, []interface{}, Event:
+		data, err := json.Marshal(x)
+		return string(data), err
+	}
+	return fmt.Sprint(v), nil
+}
+
+// ---------------------------------
+// JSON Lines
+// ---------------------------------
+
+type JSONLinesOptions struct {
+	TimeFormat string // The layout of the time values, IntervalTimeFormat if empty.
+}
+
+// JSONLinesWriter writes every row as a JSON object in a line, with the keys in the order of the columns.
+type JSONLinesWriter struct {
+	w       io.Writer
+	opts    JSONLinesOptions
+	columns [][]byte // The JSON encoded column names.
+	buf     bytes.Buffer
+}
+
+func NewJSONLinesWriter(w io.Writer, opts JSONLinesOptions) *JSONLinesWriter {
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = IntervalTimeFormat
+	}
+	return &JSONLinesWriter{w: w, opts: opts}
+}
+
+func (w *JSONLinesWriter) WriteHeader(columns []TableColumn) error {
+	w.columns = make([][]byte, len(columns))
+	for i, c := range columns {
+		name, err := json.Marshal(c.Name)
+		if err != nil {
+			return err
+		}
+		w.columns[i] = name
+	}
+	return nil
+}
+
+func (w *JSONLinesWriter) WriteRow(row []interface{}) error {
+	w.buf.Reset()
+	w.buf.WriteByte('{')
+	for i, v := range row {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+		w.buf.Write(w.columns[i])
+		w.buf.WriteByte(':')
+		if t, ok := v.(time.Time); ok {
+			v = t.Format(w.opts.TimeFormat)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.buf.Write(data)
+	}
+	w.buf.WriteString("}\n")
+	_, err := w.w.Write(w.buf.Bytes())
+	return err
+}
+
+func (w *JSONLinesWriter) Close() error {
+	return nil
+}
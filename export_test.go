@@ -0,0 +1,29 @@
+package godruid
+
+import (
+	"bytes"
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	Convey("TestExport", t, func() {
+		query := &QueryTimeseries{
+			Aggregations: []Aggregation{AggLongSum("bytes", "bytes"), AggDoubleSum("ratio", "ratio")},
+			QueryResult: []Timeseries{
+				{Timestamp: "2015-01-01T00:00:00.000Z", Result: Event{"bytes": json.Number("9007199254740993"), "ratio": nil}},
+				{Timestamp: "2015-01-02T00:00:00.000Z", Result: Event{"bytes": json.Number("2"), "ratio": 0.5}},
+			},
+		}
+
+		var buf bytes.Buffer
+		So(WriteQuery(NewCSVWriter(&buf, CSVOptions{Null: "NULL", TimeFormat: "2006-01-02"}), query), ShouldEqual, nil)
+		So(buf.String(), ShouldEqual, "timestamp,bytes,ratio\n2015-01-01,9007199254740993,NULL\n2015-01-02,2,0.5\n")
+
+		buf.Reset()
+		So(WriteQuery(NewJSONLinesWriter(&buf, JSONLinesOptions{}), query), ShouldEqual, nil)
+		So(buf.String(), ShouldEqual, `{"timestamp":"2015-01-01T00:00:00.000Z","bytes":9007199254740993,"ratio":null}`+"\n"+
+			`{"timestamp":"2015-01-02T00:00:00.000Z","bytes":2,"ratio":0.5}`+"\n")
+	})
+}
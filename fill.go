@@ -0,0 +1,178 @@
+package godruid
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FillEmptyBuckets inserts the time buckets missing from QueryResult, so that there is
+// one result for every bucket of the query's Granularity in its Intervals.
+//
+// The metrics (aggregations and post aggregations) of the inserted buckets are 0, unless
+// they are in values, e.g. map[string]interface{}{"avg": nil} fills "avg" with null.
+// It does nothing for the "all" granularity.
+func (q *QueryTimeseries) FillEmptyBuckets(values map[string]interface{}) error {
+	buckets, err := bucketStarts(q.Granularity, q.Intervals)
+	if err != nil || buckets == nil {
+		return err
+	}
+	seen, err := timestampSet(len(q.QueryResult), func(i int) string { return q.QueryResult[i].Timestamp })
+	if err != nil {
+		return err
+	}
+	for _, start := range buckets {
+		if !seen[start.UnixNano()] {
+			q.QueryResult = append(q.QueryResult, Timeseries{
+				Timestamp: start.Format(IntervalTimeFormat),
+				Result:    fillRow(q.Aggregations, q.PostAggregations, values),
+			})
+		}
+	}
+	sort.SliceStable(q.QueryResult, func(a, b int) bool {
+		return timestampBefore(q.QueryResult[a].Timestamp, q.QueryResult[b].Timestamp)
+	})
+	return nil
+}
+
+// FillEmptyBuckets inserts the rows missing from QueryResult, so that every combination of
+// the dimensions in QueryResult has a row in every bucket of the query's Granularity in its Intervals.
+//
+// The metrics (aggregations and post aggregations) of the inserted rows are 0, unless
+// they are in values, e.g. map[string]interface{}{"avg": nil} fills "avg" with null.
+// It does nothing for the "all" granularity.
+func (q *QueryGroupBy) FillEmptyBuckets(values map[string]interface{}) error {
+	buckets, err := bucketStarts(q.Granularity, q.Intervals)
+	if err != nil || buckets == nil {
+		return err
+	}
+	dims := make([]string, len(q.Dimensions))
+	for i, dim := range q.Dimensions {
+		dims[i] = dimOutputName(dim)
+	}
+
+	version := "v1"
+	var combos []map[string]interface{}
+	comboSeen := map[string]bool{}
+	rowSeen := map[string]bool{}
+	for _, item := range q.QueryResult {
+		t, err := parseISOTime(item.Timestamp)
+		if err != nil {
+			return err
+		}
+		combo := make(map[string]interface{}, len(dims))
+		keys := make([]string, len(dims))
+		for i, dim := range dims {
+			combo[dim] = item.Event[dim]
+			keys[i] = fmt.Sprint(item.Event[dim])
+		}
+		key := strings.Join(keys, "\x00")
+		if !comboSeen[key] {
+			comboSeen[key] = true
+			combos = append(combos, combo)
+		}
+		rowSeen[fmt.Sprint(t.UnixNano(), "\x01", key)] = true
+		version = item.Version
+	}
+
+	for _, start := range buckets {
+		for _, combo := range combos {
+			keys := make([]string, len(dims))
+			for i, dim := range dims {
+				keys[i] = fmt.Sprint(combo[dim])
+			}
+			if rowSeen[fmt.Sprint(start.UnixNano(), "\x01", strings.Join(keys, "\x00"))] {
+				continue
+			}
+			event := fillRow(q.Aggregations, q.PostAggregations, values)
+			for dim, v := range combo {
+				event[dim] = v
+			}
+			q.QueryResult = append(q.QueryResult, GroupbyItem{
+				Version:   version,
+				Timestamp: start.Format(IntervalTimeFormat),
+				Event:     event,
+			})
+		}
+	}
+	// Sort by time and then the dimensions the same as Druid, so the inserted rows are
+	// among the others.
+	res := q.QueryResult
+	sort.SliceStable(res, func(a, b int) bool {
+		ta, errA := parseISOTime(res[a].Timestamp)
+		tb, errB := parseISOTime(res[b].Timestamp)
+		if errA == nil && errB == nil && !ta.Equal(tb) {
+			return ta.Before(tb)
+		}
+		for _, dim := range dims {
+			if cmp := compareValues(res[a].Event[dim], res[b].Event[dim], false); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+// bucketStarts returns the start times of the buckets of gran in intervals, or nil for the "all" granularity.
+func bucketStarts(gran Granlarity, ss []string) ([]time.Time, error) {
+	b, err := BucketerOf(gran)
+	if err != nil || b.IsAll() {
+		return nil, err
+	}
+	intervals, err := ParseIntervals(ss)
+	if err != nil {
+		return nil, err
+	}
+	var starts []time.Time
+	seen := map[int64]bool{}
+	for _, i := range intervals.Normalize() {
+		for _, bucket := range b.Buckets(i) {
+			if !seen[bucket.Start.UnixNano()] {
+				seen[bucket.Start.UnixNano()] = true
+				starts = append(starts, bucket.Start)
+			}
+		}
+	}
+	return starts, nil
+}
+
+func timestampSet(n int, timestamp func(i int) string) (map[int64]bool, error) {
+	seen := make(map[int64]bool, n)
+	for i := 0; i < n; i++ {
+		t, err := parseISOTime(timestamp(i))
+		if err != nil {
+			return nil, err
+		}
+		seen[t.UnixNano()] = true
+	}
+	return seen, nil
+}
+
+func timestampBefore(a, b string) bool {
+	ta, errA := parseISOTime(a)
+	tb, errB := parseISOTime(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return ta.Before(tb)
+}
+
+func fillRow(aggs []Aggregation, postAggs []PostAggregation, values map[string]interface{}) map[string]interface{} {
+	row := make(map[string]interface{}, len(aggs)+len(postAggs))
+	fill := func(name string) {
+		if v, ok := values[name]; ok {
+			row[name] = v
+		} else {
+			row[name] = 0
+		}
+	}
+	for _, agg := range aggs {
+		fill(agg.Name)
+	}
+	for _, pa := range postAggs {
+		fill(pa.Name)
+	}
+	return row
+}
@@ -0,0 +1,50 @@
+package godruid
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestFillEmptyBuckets(t *testing.T) {
+	Convey("TestFillEmptyBuckets", t, func() {
+		interval := Between(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2015, 1, 4, 0, 0, 0, 0, time.UTC))
+
+		ts := &QueryTimeseries{
+			Granularity:      GranDay,
+			Intervals:        Intervals{interval}.Strings(),
+			Aggregations:     []Aggregation{AggCount("count")},
+			PostAggregations: []PostAggregation{PostAggConstant("ratio", 1)},
+			QueryResult: []Timeseries{
+				{Timestamp: "2015-01-02T00:00:00.000Z", Result: map[string]interface{}{"count": 3.0, "ratio": 1.0}},
+			},
+		}
+		So(ts.FillEmptyBuckets(map[string]interface{}{"ratio": nil}), ShouldEqual, nil)
+		So(ts.QueryResult, ShouldResemble, []Timeseries{
+			{Timestamp: "2015-01-01T00:00:00.000Z", Result: map[string]interface{}{"count": 0, "ratio": nil}},
+			{Timestamp: "2015-01-02T00:00:00.000Z", Result: map[string]interface{}{"count": 3.0, "ratio": 1.0}},
+			{Timestamp: "2015-01-03T00:00:00.000Z", Result: map[string]interface{}{"count": 0, "ratio": nil}},
+		})
+
+		gb := &QueryGroupBy{
+			Granularity:  GranDay,
+			Intervals:    Intervals{interval}.Strings(),
+			Dimensions:   []DimSpec{"country"},
+			Aggregations: []Aggregation{AggCount("count")},
+			QueryResult: []GroupbyItem{
+				{Version: "v1", Timestamp: "2015-01-01T00:00:00.000Z", Event: map[string]interface{}{"country": "cn", "count": 1.0}},
+				{Version: "v1", Timestamp: "2015-01-03T00:00:00.000Z", Event: map[string]interface{}{"country": "us", "count": 2.0}},
+			},
+		}
+		So(gb.FillEmptyBuckets(nil), ShouldEqual, nil)
+		So(len(gb.QueryResult), ShouldEqual, 6)
+		So(gb.QueryResult[1], ShouldResemble, GroupbyItem{Version: "v1", Timestamp: "2015-01-01T00:00:00.000Z", Event: map[string]interface{}{"country": "us", "count": 0}})
+		// The filled "cn" goes before the real "us" of the same bucket.
+		So(gb.QueryResult[4], ShouldResemble, GroupbyItem{Version: "v1", Timestamp: "2015-01-03T00:00:00.000Z", Event: map[string]interface{}{"country": "cn", "count": 0}})
+		So(gb.QueryResult[5].Event["country"], ShouldEqual, "us")
+
+		gb.Granularity = GranAll
+		So(gb.FillEmptyBuckets(nil), ShouldEqual, nil)
+		So(len(gb.QueryResult), ShouldEqual, 6)
+	})
+}
@@ -1,13 +1,14 @@
 package godruid
 
 type Filter struct {
-	Type      string      `json:"type"`
-	Dimension string      `json:"dimension,omitempty"`
-	Value     interface{} `json:"value,omitempty"`
-	Pattern   string      `json:"pattern,omitempty"`
-	Function  string      `json:"function,omitempty"`
-	Field     *Filter     `json:"field,omitempty"`
-	Fields    []*Filter   `json:"fields,omitempty"`
+	Type      string        `json:"type"`
+	Dimension string        `json:"dimension,omitempty"`
+	Value     interface{}   `json:"value,omitempty"`
+	Values    []interface{} `json:"values,omitempty"`
+	Pattern   string        `json:"pattern,omitempty"`
+	Function  string        `json:"function,omitempty"`
+	Field     *Filter       `json:"field,omitempty"`
+	Fields    []*Filter     `json:"fields,omitempty"`
 }
 
 func FilterSelector(dimension string, value interface{}) *Filter {
@@ -18,6 +19,14 @@ func FilterSelector(dimension string, value interface{}) *Filter {
 	}
 }
 
+func FilterIn(dimension string, values ...interface{}) *Filter {
+	return &Filter{
+		Type:      "in",
+		Dimension: dimension,
+		Values:    values,
+	}
+}
+
 func FilterRegex(dimension, pattern string) *Filter {
 	return &Filter{
 		Type:      "regex",
@@ -0,0 +1,148 @@
+package godruid
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// The context keys which don't change the results of queries.
+var volatileContextKeys = []string{
+	"queryId", "sqlQueryId", "timeout", "priority", "lane",
+	"useCache", "populateCache", "useResultLevelCache", "populateResultLevelCache",
+	"maxScatterGatherBytes", "maxQueuedBytes", "chunkPeriod", "vectorize", "vectorSize",
+}
+
+// Fingerprint returns a stable hash of query, the same for the queries which are
+// semantically identical but built differently: the intervals are normalized, the
+// children of the "and" and "or" filters and havings and the values of the "in"
+// filters are sorted, and the context keys which don't change the results, like
+// queryId and priority, are removed. It returns "" if query can't be marshalled.
+func Fingerprint(query Query) string {
+	query.setup()
+	reqJson, err := json.Marshal(query)
+	if err != nil {
+		return ""
+	}
+	key, err := fingerprint(reqJson)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// fingerprintOnce returns the fingerprint of reqJson, computed once for a query sent with
+// ctx and shared by the coalescer and the cache.
+func fingerprintOnce(ctx context.Context, reqJson []byte) (string, error) {
+	x := responseExchangeFrom(ctx)
+	if x != nil && x.fingerprint != "" {
+		return x.fingerprint, nil
+	}
+	fp, err := fingerprint(reqJson)
+	if err == nil && x != nil {
+		x.fingerprint = fp
+	}
+	return fp, err
+}
+
+// fingerprint returns the hash of the canonicalized query JSON.
+func fingerprint(reqJson []byte) (string, error) {
+	var m map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(reqJson))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil {
+		return "", err
+	}
+	if ctx, ok := m["context"].(map[string]interface{}); ok {
+		for _, key := range volatileContextKeys {
+			delete(ctx, key)
+		}
+		if len(ctx) == 0 {
+			delete(m, "context")
+		}
+	}
+	if intervals, ok := m["intervals"].([]interface{}); ok {
+		m["intervals"] = canonicalIntervals(intervals)
+	}
+	canonicalize(m)
+
+	// encoding/json sorts the map keys.
+	canonical, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalIntervals returns the normalized intervals in UTC, or the intervals
+// untouched if any of them can't be parsed.
+func canonicalIntervals(intervals []interface{}) interface{} {
+	is := make(Intervals, len(intervals))
+	for i, v := range intervals {
+		s, ok := v.(string)
+		if !ok {
+			return intervals
+		}
+		interval, err := ParseInterval(s)
+		if err != nil {
+			return intervals
+		}
+		is[i] = Between(interval.Start.UTC(), interval.End.UTC())
+	}
+	res := make([]interface{}, 0, len(is))
+	for _, interval := range is.Normalize() {
+		res = append(res, interval.String())
+	}
+	return res
+}
+
+// canonicalize sorts the commutative parts of v in place, the children first.
+func canonicalize(v interface{}) {
+	switch x := v.(type) {
+	case []interface{}:
+		for _, e := range x {
+			canonicalize(e)
+		}
+	case map[string]interface{}:
+		for _, e := range x {
+			canonicalize(e)
+		}
+		switch x["type"] {
+		case "and", "or":
+			sortByJSON(x["fields"])
+			sortByJSON(x["havingSpecs"])
+		case "in":
+			sortByJSON(x["values"])
+		}
+	}
+}
+
+// sortByJSON sorts v by the JSON of its elements if v is an array.
+func sortByJSON(v interface{}) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return
+	}
+	keys := make([]string, len(list))
+	for i, e := range list {
+		data, _ := json.Marshal(e)
+		keys[i] = string(data)
+	}
+	sort.Sort(byKeys{list, keys})
+}
+
+type byKeys struct {
+	list []interface{}
+	keys []string
+}
+
+func (s byKeys) Len() int           { return len(s.list) }
+func (s byKeys) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s byKeys) Swap(i, j int) {
+	s.list[i], s.list[j] = s.list[j], s.list[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
@@ -0,0 +1,55 @@
+package godruid
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	Convey("TestFingerprint", t, func() {
+		query1 := &QueryTimeseries{
+			DataSource:  "events",
+			Intervals:   []string{"2015-01-01/2015-01-02", "2015-01-02/2015-01-03"},
+			Granularity: GranDay,
+			Filter: FilterAnd(
+				FilterSelector("country", "cn"),
+				FilterOr(FilterIn("os", "ios", "android"), FilterRegex("app", "^a")),
+			),
+			Aggregations: []Aggregation{AggCount("count")},
+			Context:      &QueryContext{QueryId: "q1", Priority: Int(1)},
+		}
+		query2 := &QueryTimeseries{
+			DataSource:  "events",
+			Intervals:   []string{"2015-01-01T08:00:00+08:00/2015-01-03T08:00:00+08:00"},
+			Granularity: GranDay,
+			Filter: FilterAnd(
+				FilterOr(FilterRegex("app", "^a"), FilterIn("os", "android", "ios")),
+				FilterSelector("country", "cn"),
+			),
+			Aggregations: []Aggregation{AggCount("count")},
+		}
+		So(Fingerprint(query1), ShouldNotEqual, "")
+		So(Fingerprint(query1), ShouldEqual, Fingerprint(query2))
+
+		query2.Context = &QueryContext{SkipEmptyBuckets: Bool(true)}
+		So(Fingerprint(query1), ShouldNotEqual, Fingerprint(query2))
+		query2.Context = nil
+		query2.Filter = FilterAnd(FilterSelector("country", "cn"), FilterIn("os", "ios"))
+		So(Fingerprint(query1), ShouldNotEqual, Fingerprint(query2))
+	})
+}
+
+func TestFingerprintOnce(t *testing.T) {
+	Convey("TestFingerprintOnce", t, func() {
+		ctx, x := withResponseExchange(context.Background())
+		fp, err := fingerprintOnce(ctx, []byte(`{"queryType":"timeseries"}`))
+		So(err, ShouldEqual, nil)
+		So(x.fingerprint, ShouldEqual, fp)
+
+		// The cache takes the fingerprint computed by the coalescer.
+		again, err := fingerprintOnce(ctx, []byte(`not parsed again`))
+		So(err, ShouldEqual, nil)
+		So(again, ShouldEqual, fp)
+	})
+}
@@ -1,5 +1,11 @@
 package godruid
 
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
 type Granlarity interface{}
 
 type SimpleGran string
@@ -7,20 +13,61 @@ type SimpleGran string
 const (
 	GranAll        SimpleGran = "all"
 	GranNone       SimpleGran = "none"
+	GranSecond     SimpleGran = "second"
 	GranMinute     SimpleGran = "minute"
+	GranFiveMinute SimpleGran = "five_minute"
+	GranTenMinute  SimpleGran = "ten_minute"
 	GranFifteenMin SimpleGran = "fifteen_minute"
 	GranThirtyMin  SimpleGran = "thirty_minute"
 	GranHour       SimpleGran = "hour"
+	GranSixHour    SimpleGran = "six_hour"
+	GranEightHour  SimpleGran = "eight_hour"
 	GranDay        SimpleGran = "day"
+	GranWeek       SimpleGran = "week"
+	GranMonth      SimpleGran = "month"
+	GranQuarter    SimpleGran = "quarter"
+	GranYear       SimpleGran = "year"
 )
 
+// The periods of the simple granularities, in UTC.
+var simpleGranPeriods = map[SimpleGran]Period{
+	GranNone:       {Time: time.Millisecond},
+	GranSecond:     {Time: time.Second},
+	GranMinute:     {Time: time.Minute},
+	GranFiveMinute: {Time: 5 * time.Minute},
+	GranTenMinute:  {Time: 10 * time.Minute},
+	GranFifteenMin: {Time: 15 * time.Minute},
+	GranThirtyMin:  {Time: 30 * time.Minute},
+	GranHour:       {Time: time.Hour},
+	GranSixHour:    {Time: 6 * time.Hour},
+	GranEightHour:  {Time: 8 * time.Hour},
+	GranDay:        {Days: 1},
+	GranWeek:       {Weeks: 1},
+	GranMonth:      {Months: 1},
+	GranQuarter:    {Months: 3},
+	GranYear:       {Years: 1},
+}
+
 type GranDuration struct {
 	Type string `json:"type"`
 
-	Duration string `json:"duration"`
+	Duration string `json:"duration"` // In milliseconds.
 	Origin   string `json:"origin,omitempty"`
 }
 
+// NewGranDuration returns the duration granularity of d, the buckets start from origin
+// if given, or else from 1970-01-01T00:00:00Z.
+func NewGranDuration(d time.Duration, origin ...time.Time) *GranDuration {
+	g := &GranDuration{
+		Type:     "duration",
+		Duration: strconv.FormatInt(int64(d/time.Millisecond), 10),
+	}
+	if len(origin) != 0 {
+		g.Origin = origin[0].Format(IntervalTimeFormat)
+	}
+	return g
+}
+
 type GranPeriod struct {
 	Type string `json:"type"`
 
@@ -28,3 +75,178 @@ type GranPeriod struct {
 	TimeZone string `json:"timeZone,omitempty"`
 	Origin   string `json:"origin,omitempty"`
 }
+
+// NewGranPeriod returns the period granularity of the ISO-8601 period, e.g. "P1D" or "PT6H".
+// The buckets are in time zone loc, UTC if loc is nil, and start from origin if given.
+func NewGranPeriod(period string, loc *time.Location, origin ...time.Time) (*GranPeriod, error) {
+	if _, err := ParsePeriod(period); err != nil {
+		return nil, err
+	}
+	g := &GranPeriod{
+		Type:   "period",
+		Period: period,
+	}
+	if loc != nil && loc != time.UTC {
+		if loc == time.Local {
+			return nil, fmt.Errorf("godruid: time.Local has no IANA name, load the time zone by name instead")
+		}
+		g.TimeZone = loc.String()
+	}
+	if len(origin) != 0 {
+		g.Origin = origin[0].Format(IntervalTimeFormat)
+	}
+	return g, nil
+}
+
+// Bucketer computes the time buckets of a granularity on client side the same as Druid does,
+// so that client side data could be aligned with the query results.
+type Bucketer struct {
+	all    bool
+	period Period
+	loc    *time.Location
+	origin *time.Time
+}
+
+// BucketerOf returns the Bucketer of gran, which is a SimpleGran, GranPeriod, GranDuration
+// or a pointer to them.
+func BucketerOf(gran Granlarity) (*Bucketer, error) {
+	switch g := gran.(type) {
+	case SimpleGran:
+		if g == GranAll {
+			return &Bucketer{all: true}, nil
+		}
+		p, ok := simpleGranPeriods[g]
+		if !ok {
+			return nil, fmt.Errorf("godruid: unknown granularity %q", g)
+		}
+		return &Bucketer{period: p, loc: time.UTC}, nil
+	case string:
+		return BucketerOf(SimpleGran(g))
+	case GranPeriod:
+		return BucketerOf(&g)
+	case GranDuration:
+		return BucketerOf(&g)
+	case *GranPeriod:
+		p, err := ParsePeriod(g.Period)
+		if err != nil {
+			return nil, err
+		}
+		b := &Bucketer{period: p, loc: time.UTC}
+		if g.TimeZone != "" {
+			if b.loc, err = time.LoadLocation(g.TimeZone); err != nil {
+				return nil, err
+			}
+		}
+		if g.Origin != "" {
+			origin, err := parseISOTime(g.Origin)
+			if err != nil {
+				return nil, err
+			}
+			origin = origin.In(b.loc)
+			b.origin = &origin
+		}
+		return b, nil
+	case *GranDuration:
+		ms, err := strconv.ParseInt(g.Duration, 10, 64)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("godruid: invalid duration granularity %q", g.Duration)
+		}
+		origin := time.Unix(0, 0).UTC()
+		if g.Origin != "" {
+			if origin, err = parseISOTime(g.Origin); err != nil {
+				return nil, err
+			}
+		}
+		return &Bucketer{period: Period{Time: time.Duration(ms) * time.Millisecond}, loc: time.UTC, origin: &origin}, nil
+	}
+	return nil, fmt.Errorf("godruid: unsupported granularity %v", gran)
+}
+
+// IsAll reports whether everything is in one bucket.
+func (b *Bucketer) IsAll() bool {
+	return b.all
+}
+
+// Truncate returns the start of the bucket t is in. For the "all" granularity it returns t.
+func (b *Bucketer) Truncate(t time.Time) time.Time {
+	if b.all {
+		return t
+	}
+	if b.origin != nil {
+		return b.floor(*b.origin, t)
+	}
+
+	t = t.In(b.loc)
+	p := b.period
+	y, m, d := t.Date()
+	switch {
+	case p == Period{Years: p.Years}:
+		return time.Date(y-y%p.Years, 1, 1, 0, 0, 0, 0, b.loc)
+	case p == Period{Months: p.Months}:
+		m0 := int(m) - 1
+		return time.Date(y, time.Month(m0-m0%p.Months+1), 1, 0, 0, 0, 0, b.loc)
+	case p == Period{Weeks: p.Weeks}:
+		// The weeks start on Monday and are counted from the first week of the week year.
+		_, w := t.ISOWeek()
+		weekday := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-weekday-(w-1)%p.Weeks*7, 0, 0, 0, 0, b.loc)
+	case p == Period{Days: p.Days}:
+		return time.Date(y, m, d-(d-1)%p.Days, 0, 0, 0, 0, b.loc)
+	case p.IsFixed() && (24*time.Hour)%p.Time == 0:
+		midnight := time.Date(y, m, d, 0, 0, 0, 0, b.loc)
+		return midnight.Add(t.Sub(midnight) / p.Time * p.Time)
+	}
+	epoch := time.Date(1970, 1, 1, 0, 0, 0, 0, b.loc)
+	return b.floor(epoch, t)
+}
+
+// floor returns the largest origin + k * period which is not after t.
+func (b *Bucketer) floor(origin, t time.Time) time.Time {
+	p := b.period
+	if p.IsFixed() {
+		k := t.Sub(origin) / p.Time
+		if start := origin.Add(k * p.Time); !start.After(t) {
+			return start
+		}
+		return origin.Add((k - 1) * p.Time)
+	}
+	approx := time.Duration(p.Years)*365*24*time.Hour + time.Duration(p.Months)*30*24*time.Hour +
+		time.Duration(p.Weeks*7+p.Days)*24*time.Hour + p.Time
+	k := int(t.Sub(origin) / approx)
+	for p.AddTo(origin, k).After(t) {
+		k--
+	}
+	for !p.AddTo(origin, k+1).After(t) {
+		k++
+	}
+	return p.AddTo(origin, k)
+}
+
+// Next returns the start of the bucket after the one t is in. For the "all" granularity it returns t.
+func (b *Bucketer) Next(t time.Time) time.Time {
+	if b.all {
+		return t
+	}
+	start := b.Truncate(t)
+	next := b.Truncate(b.period.AddTo(start, 1))
+	if !next.After(start) {
+		next = b.period.AddTo(start, 1)
+	}
+	return next
+}
+
+// Buckets returns the buckets covering the interval. The buckets are not cut by the interval,
+// so the first one could start before i.Start and the last one could end after i.End.
+// For the "all" granularity it returns i itself.
+func (b *Bucketer) Buckets(i Interval) Intervals {
+	if b.all || i.IsEmpty() {
+		return Intervals{i}
+	}
+	var res Intervals
+	for start := b.Truncate(i.Start); start.Before(i.End); {
+		end := b.Next(start)
+		res = append(res, Interval{start, end})
+		start = end
+	}
+	return res
+}
@@ -0,0 +1,52 @@
+package godruid
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestBucketer(t *testing.T) {
+	Convey("TestBucketer", t, func() {
+		utc := func(y int, m time.Month, d, h, min int) time.Time { return time.Date(y, m, d, h, min, 0, 0, time.UTC) }
+		at := utc(2015, 8, 13, 14, 47) // A Thursday.
+
+		truncate := func(gran Granlarity) time.Time {
+			b, err := BucketerOf(gran)
+			So(err, ShouldEqual, nil)
+			return b.Truncate(at)
+		}
+		So(truncate(GranFifteenMin), ShouldResemble, utc(2015, 8, 13, 14, 45))
+		So(truncate(GranSixHour), ShouldResemble, utc(2015, 8, 13, 12, 0))
+		So(truncate(GranDay), ShouldResemble, utc(2015, 8, 13, 0, 0))
+		So(truncate(GranWeek), ShouldResemble, utc(2015, 8, 10, 0, 0))
+		So(truncate(GranMonth), ShouldResemble, utc(2015, 8, 1, 0, 0))
+		So(truncate(GranQuarter), ShouldResemble, utc(2015, 7, 1, 0, 0))
+		So(truncate(GranYear), ShouldResemble, utc(2015, 1, 1, 0, 0))
+		So(truncate("hour"), ShouldResemble, utc(2015, 8, 13, 14, 0))
+		So(truncate(NewGranDuration(90*time.Minute)), ShouldResemble, utc(2015, 8, 13, 13, 30))
+
+		shanghai, _ := time.LoadLocation("Asia/Shanghai")
+		gran, err := NewGranPeriod("P1D", shanghai)
+		So(err, ShouldEqual, nil)
+		So(gran.TimeZone, ShouldEqual, "Asia/Shanghai")
+		So(truncate(gran).Equal(utc(2015, 8, 12, 16, 0)), ShouldBeTrue)
+
+		gran, err = NewGranPeriod("P2D", nil, utc(2015, 8, 1, 6, 0))
+		So(err, ShouldEqual, nil)
+		So(truncate(gran).Equal(utc(2015, 8, 13, 6, 0)), ShouldBeTrue)
+
+		_, err = NewGranPeriod("1D", nil)
+		So(err, ShouldNotEqual, nil)
+		_, err = BucketerOf(SimpleGran("fortnight"))
+		So(err, ShouldNotEqual, nil)
+
+		b, _ := BucketerOf(GranMonth)
+		So(b.Buckets(Between(utc(2015, 1, 15, 0, 0), utc(2015, 3, 1, 0, 0))), ShouldResemble, Intervals{
+			Between(utc(2015, 1, 1, 0, 0), utc(2015, 2, 1, 0, 0)),
+			Between(utc(2015, 2, 1, 0, 0), utc(2015, 3, 1, 0, 0)),
+		})
+		b, _ = BucketerOf(GranAll)
+		So(b.Buckets(Between(at, at.Add(time.Hour))), ShouldResemble, Intervals{Between(at, at.Add(time.Hour))})
+	})
+}
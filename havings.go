@@ -71,3 +71,56 @@ func joinHavings(havings []*Having, connector string) *Having {
 		HavingSpecs: havings,
 	}
 }
+
+// matchable reports whether the having spec could be checked on client side.
+func (h *Having) matchable() bool {
+	if h == nil {
+		return true
+	}
+	switch h.Type {
+	case "equalTo", "greaterThan", "lessThan":
+		return true
+	case "and", "or":
+		for _, sub := range h.HavingSpecs {
+			if !sub.matchable() {
+				return false
+			}
+		}
+		return true
+	case "not":
+		return h.HavingSpec.matchable()
+	}
+	return false
+}
+
+// match reports whether the row passes the having spec.
+func (h *Having) match(row map[string]interface{}) bool {
+	if h == nil {
+		return true
+	}
+	switch h.Type {
+	case "equalTo":
+		return compareNumbers(row[h.Aggregation], h.Value) == 0
+	case "greaterThan":
+		return compareNumbers(row[h.Aggregation], h.Value) > 0
+	case "lessThan":
+		return compareNumbers(row[h.Aggregation], h.Value) < 0
+	case "and":
+		for _, sub := range h.HavingSpecs {
+			if !sub.match(row) {
+				return false
+			}
+		}
+		return true
+	case "or":
+		for _, sub := range h.HavingSpecs {
+			if sub.match(row) {
+				return true
+			}
+		}
+		return false
+	case "not":
+		return !h.HavingSpec.match(row)
+	}
+	return true
+}
@@ -0,0 +1,206 @@
+package godruid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// QueryIncremental sends a timeseries or groupBy query with the results cached per time
+// bucket of its granularity in Client.Cache. Only the buckets not cached yet and the ones
+// still mutable, i.e. touching now, are queried from the broker, with one query on their
+// intervals through the Interceptors, and the results are stitched together in the bucket order. The other queries,
+// and the queries with the "all" granularity, are sent by QueryWithContext.
+//
+// The LimitSpec of groupBy queries is applied after stitching, and the grandTotal of
+// timeseries queries is not supported.
+func (c *Client) QueryIncremental(ctx context.Context, query Query) (err error) {
+	var gran Granlarity
+	switch q := query.(type) {
+	case *QueryTimeseries:
+		if qctx := c.queryContext(q); qctx != nil && qctx.GrandTotal != nil && *qctx.GrandTotal {
+			return fmt.Errorf("godruid: QueryIncremental doesn't support grandTotal")
+		}
+		gran = q.Granularity
+	case *QueryGroupBy:
+		gran = q.Granularity
+	}
+	if gran == nil || c.Cache == nil {
+		return c.QueryWithContext(ctx, query)
+	}
+	bucketer, err := BucketerOf(gran)
+	if err != nil {
+		return err
+	}
+	if bucketer.IsAll() {
+		return c.QueryWithContext(ctx, query)
+	}
+	ctx, hooks := withDecodeHooks(ctx, query)
+	defer func() { hooks.run(err) }()
+
+	// The key of a bucket is the fingerprint of the query without intervals plus the
+	// parts of the intervals in the bucket.
+	reqJson, err := c.marshalQuery(withIntervals(query, nil))
+	if err != nil {
+		return err
+	}
+	fp, err := fingerprint(reqJson)
+	if err != nil {
+		return err
+	}
+	queryKey := c.cacheKey(fp)
+
+	intervals, err := ParseIntervals(query.getIntervals())
+	if err != nil {
+		return err
+	}
+	buckets := splitBuckets(bucketer, intervals)
+	opts := cacheOptionsFrom(ctx)
+	var missing Intervals
+	for _, b := range buckets {
+		b.key = queryKey + fmt.Sprint(b.pieces)
+		b.mutable = touchesNow(b.pieces)
+		if !b.mutable && !opts.bypass {
+			rows, ok := c.Cache.Get(b.key)
+			ok = ok && json.Unmarshal(rows, &b.rows) == nil
+			if c.Metrics != nil {
+				c.Metrics.CacheLookup(c.metricLabels(reqJson), ok)
+			}
+			if ok {
+				b.cached = true
+				continue
+			}
+		}
+		missing = append(missing, b.pieces...)
+	}
+
+	if len(missing) > 0 {
+		part := withIntervals(query, missing)
+		if reqJson, err = c.marshalQuery(part); err != nil {
+			return err
+		}
+		ctx, x := withResponseExchange(ctx)
+		result, err := c.intercept(c.queryRaw)(ctx, part, reqJson)
+		if err != nil {
+			return err
+		}
+		if err := fillBuckets(bucketer, buckets, result); err != nil {
+			return err
+		}
+		for _, b := range buckets {
+			if b.cached || b.mutable || x.meta.Incomplete() {
+				continue
+			}
+			rows, err := json.Marshal(b.rows)
+			if err != nil {
+				return err
+			}
+			c.Cache.Set(b.key, rows, c.cacheTTL(b.pieces, opts))
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	n := 0
+	for _, b := range buckets {
+		for _, row := range b.rows {
+			if n > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(row)
+			n++
+		}
+	}
+	buf.WriteByte(']')
+	if err := query.onResponse(buf.Bytes()); err != nil {
+		return err
+	}
+	if q, ok := query.(*QueryGroupBy); ok {
+		q.QueryResult = q.LimitSpec.apply(q.QueryResult)
+	}
+	return nil
+}
+
+// A resultBucket is a time bucket of the granularity and its results.
+type resultBucket struct {
+	start   time.Time
+	pieces  Intervals // The parts of the query intervals in the bucket.
+	key     string
+	mutable bool
+	cached  bool
+	rows    []json.RawMessage
+}
+
+// splitBuckets returns the buckets of intervals in time order.
+func splitBuckets(bucketer *Bucketer, intervals Intervals) []*resultBucket {
+	var res []*resultBucket
+	for _, i := range intervals.Normalize() {
+		for _, bucket := range bucketer.Buckets(i) {
+			piece, ok := bucket.Intersect(i)
+			if !ok {
+				continue
+			}
+			// Different intervals could fall into the same bucket.
+			if n := len(res); n > 0 && res[n-1].start.Equal(bucket.Start) {
+				res[n-1].pieces = append(res[n-1].pieces, piece)
+				continue
+			}
+			res = append(res, &resultBucket{start: bucket.Start, pieces: Intervals{piece}})
+		}
+	}
+	return res
+}
+
+// fillBuckets puts the rows of result into the buckets of their timestamps.
+func fillBuckets(bucketer *Bucketer, buckets []*resultBucket, result []byte) error {
+	var rows []json.RawMessage
+	if err := json.Unmarshal(result, &rows); err != nil {
+		return err
+	}
+	index := make(map[int64]*resultBucket, len(buckets))
+	for _, b := range buckets {
+		if !b.cached {
+			b.rows = nil
+			index[b.start.UnixNano()] = b
+		}
+	}
+	for _, row := range rows {
+		var item struct {
+			Timestamp string `json:"timestamp"`
+		}
+		if err := json.Unmarshal(row, &item); err != nil {
+			return err
+		}
+		ts, err := parseISOTime(item.Timestamp)
+		if err != nil {
+			return err
+		}
+		b, ok := index[bucketer.Truncate(ts).UnixNano()]
+		if !ok {
+			return fmt.Errorf("godruid: result of %s is out of the queried buckets", item.Timestamp)
+		}
+		b.rows = append(b.rows, row)
+	}
+	return nil
+}
+
+// withIntervals returns a copy of the timeseries or groupBy query on intervals,
+// the LimitSpec of groupBy is removed as it applies to the whole results.
+func withIntervals(query Query, intervals Intervals) Query {
+	switch q := query.(type) {
+	case *QueryTimeseries:
+		part := *q
+		part.Intervals = intervals.Strings()
+		part.QueryResult = nil
+		return &part
+	case *QueryGroupBy:
+		part := *q
+		part.Intervals = intervals.Strings()
+		part.LimitSpec = nil
+		part.QueryResult = nil
+		return &part
+	}
+	return query
+}
@@ -0,0 +1,74 @@
+package godruid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryIncremental(t *testing.T) {
+	Convey("TestQueryIncremental", t, func() {
+		// The broker answers a row of count 1 for every day of the asked intervals.
+		var asked []Intervals
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			var req struct {
+				Intervals Intervals `json:"intervals"`
+			}
+			json.Unmarshal(body, &req)
+			asked = append(asked, req.Intervals)
+			var rows []string
+			for _, i := range req.Intervals {
+				for _, day := range i.SplitBy(MustParsePeriod("P1D")) {
+					rows = append(rows, fmt.Sprintf(`{"timestamp":%q,"result":{"count":1}}`,
+						day.Start.UTC().Format(IntervalTimeFormat)))
+				}
+			}
+			w.Write([]byte("[" + strings.Join(rows, ",") + "]"))
+		}))
+		defer server.Close()
+
+		client := Client{Url: server.URL, Cache: NewLRUCache(1 << 20)}
+		newQuery := func(interval string) *QueryTimeseries {
+			return &QueryTimeseries{
+				DataSource:   "events",
+				Intervals:    []string{interval},
+				Granularity:  GranDay,
+				Aggregations: []Aggregation{AggCount("count")},
+			}
+		}
+
+		query := newQuery("2015-01-01/2015-01-04")
+		So(client.QueryIncremental(context.Background(), query), ShouldEqual, nil)
+		So(len(query.QueryResult), ShouldEqual, 3)
+		So(len(asked), ShouldEqual, 1)
+
+		query = newQuery("2015-01-02/2015-01-06")
+		So(client.QueryIncremental(context.Background(), query), ShouldEqual, nil)
+		So(len(asked), ShouldEqual, 2)
+		So(asked[1], ShouldResemble, Intervals{
+			MustParseInterval("2015-01-04/2015-01-05"), MustParseInterval("2015-01-05/2015-01-06")})
+		So(len(query.QueryResult), ShouldEqual, 4)
+		So(query.QueryResult[0].Timestamp, ShouldEqual, "2015-01-02T00:00:00.000Z")
+		So(query.QueryResult[3].Timestamp, ShouldEqual, "2015-01-05T00:00:00.000Z")
+
+		Convey("the buckets touching now are always queried", func() {
+			today := time.Now().UTC().Truncate(24 * time.Hour)
+			interval := Between(today.AddDate(0, 0, -2), today.AddDate(0, 0, 1)).String()
+			for n := 0; n < 2; n++ {
+				query = newQuery(interval)
+				So(client.QueryIncremental(context.Background(), query), ShouldEqual, nil)
+				So(len(query.QueryResult), ShouldEqual, 3)
+			}
+			So(len(asked), ShouldEqual, 4)
+			So(asked[3], ShouldResemble, Intervals{Between(today, today.AddDate(0, 0, 1))})
+		})
+	})
+}
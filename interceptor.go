@@ -0,0 +1,32 @@
+package godruid
+
+import "context"
+
+// QueryFunc sends the query marshalled as req, and returns the raw response.
+type QueryFunc func(ctx context.Context, query Query, req []byte) (result []byte, err error)
+
+// Interceptor wraps a QueryFunc, e.g. to modify the requests or the responses, or to
+// retry the failed queries. It could skip calling next and return the response itself.
+// The retries should be sent with NextAttempt(ctx) to be told from the first attempts.
+type Interceptor func(next QueryFunc) QueryFunc
+
+// ChainInterceptors returns the interceptor composed of interceptors, the first one is
+// the outermost one.
+func ChainInterceptors(interceptors ...Interceptor) Interceptor {
+	return func(next QueryFunc) QueryFunc {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next = interceptors[i](next)
+		}
+		return next
+	}
+}
+
+// intercept returns final wrapped by the Interceptors of Client.
+func (c *Client) intercept(final QueryFunc) QueryFunc {
+	return ChainInterceptors(c.Interceptors...)(final)
+}
+
+// queryRaw is QueryRawWithContext as a QueryFunc.
+func (c *Client) queryRaw(ctx context.Context, query Query, req []byte) ([]byte, error) {
+	return c.QueryRawWithContext(ctx, req)
+}
@@ -0,0 +1,58 @@
+package godruid
+
+import (
+	"context"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestInterceptors(t *testing.T) {
+	Convey("TestInterceptors", t, func() {
+		server, got := fakeBroker(map[string]string{
+			"2015-01-01": `[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":3}}]`,
+		})
+		defer server.Close()
+
+		var calls []string
+		record := func(name string) Interceptor {
+			return func(next QueryFunc) QueryFunc {
+				return func(ctx context.Context, query Query, req []byte) ([]byte, error) {
+					calls = append(calls, name+" "+query.getDataSource())
+					result, err := next(ctx, query, req)
+					calls = append(calls, name+" done")
+					return result, err
+				}
+			}
+		}
+		client := Client{Url: server.URL, Interceptors: []Interceptor{record("a"), record("b")}}
+		query := &QueryTimeseries{
+			DataSource:   "events",
+			Intervals:    []string{"2015-01-01/2015-01-02"},
+			Granularity:  GranAll,
+			Aggregations: []Aggregation{AggCount("count")},
+		}
+		So(client.Query(query), ShouldEqual, nil)
+		So(calls, ShouldResemble, []string{"a events", "b events", "b done", "a done"})
+		So(len(query.QueryResult), ShouldEqual, 1)
+
+		Convey("an interceptor could answer the query itself", func() {
+			client.Interceptors = append(client.Interceptors, func(next QueryFunc) QueryFunc {
+				return func(ctx context.Context, query Query, req []byte) ([]byte, error) {
+					return []byte(`[]`), nil
+				}
+			})
+			So(client.Query(query), ShouldEqual, nil)
+			So(len(query.QueryResult), ShouldEqual, 0)
+			So(len(*got), ShouldEqual, 1)
+
+			failed := errors.New("failed")
+			client.Interceptors = []Interceptor{func(next QueryFunc) QueryFunc {
+				return func(ctx context.Context, query Query, req []byte) ([]byte, error) {
+					return nil, failed
+				}
+			}}
+			So(client.Query(query), ShouldEqual, failed)
+		})
+	})
+}
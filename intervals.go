@@ -0,0 +1,321 @@
+package godruid
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interval is the half-open time range [Start, End). It is serialized as the
+// ISO-8601 "start/end" string Druid expects.
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Intervals is the intervals of a query, Strings returns them for the Intervals of the queries,
+// e.g. Intervals{LastN(24 * time.Hour)}.Strings().
+type Intervals []Interval
+
+// The time format used while serializing intervals.
+const IntervalTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+func Between(start, end time.Time) Interval {
+	return Interval{Start: start, End: end}
+}
+
+// LastN returns the interval of the last d until now.
+func LastN(d time.Duration) Interval {
+	now := time.Now().UTC()
+	return Interval{Start: now.Add(-d), End: now}
+}
+
+// ParseInterval parses the ISO-8601 interval in forms of "start/end", "start/period" and "period/end".
+// The times without a time zone are in UTC, the same as Druid does.
+func ParseInterval(s string) (i Interval, err error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return i, fmt.Errorf("godruid: invalid ISO-8601 interval %q", s)
+	}
+	switch {
+	case strings.HasPrefix(parts[0], "P"):
+		p, err := ParsePeriod(parts[0])
+		if err != nil {
+			return i, err
+		}
+		if i.End, err = parseISOTime(parts[1]); err != nil {
+			return i, err
+		}
+		i.Start = p.AddTo(i.End, -1)
+	case strings.HasPrefix(parts[1], "P"):
+		p, err := ParsePeriod(parts[1])
+		if err != nil {
+			return i, err
+		}
+		if i.Start, err = parseISOTime(parts[0]); err != nil {
+			return i, err
+		}
+		i.End = p.AddTo(i.Start, 1)
+	default:
+		if i.Start, err = parseISOTime(parts[0]); err != nil {
+			return i, err
+		}
+		if i.End, err = parseISOTime(parts[1]); err != nil {
+			return i, err
+		}
+	}
+	if i.End.Before(i.Start) {
+		return i, fmt.Errorf("godruid: interval %q ends before it starts", s)
+	}
+	return i, nil
+}
+
+func MustParseInterval(s string) Interval {
+	i, err := ParseInterval(s)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+// ParseIntervals parses the intervals of a query, e.g. the Intervals of QueryTimeseries.
+func ParseIntervals(ss []string) (Intervals, error) {
+	if ss == nil {
+		return nil, nil
+	}
+	is := make(Intervals, len(ss))
+	for k, s := range ss {
+		i, err := ParseInterval(s)
+		if err != nil {
+			return nil, err
+		}
+		is[k] = i
+	}
+	return is, nil
+}
+
+// isoTimePattern matches the ISO-8601 times Druid accepts: the dates of 4 or more digit
+// years with an optional sign, e.g. the ones of ETERNITY, the times of hours, minutes or
+// seconds with the fractions, and the offsets "Z", "+08", "+0800" or "+08:00".
+var isoTimePattern = regexp.MustCompile(`^([+-]?\d{4,})(?:-(\d{2})(?:-(\d{2})(?:T(\d{2})(?::(\d{2})(?::(\d{2})(?:[.,](\d{1,9}))?)?)?)?)?)?` +
+	`(Z|[+-]\d{2}(?::?\d{2})?)?$`)
+
+func parseISOTime(s string) (time.Time, error) {
+	m := isoTimePattern.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("godruid: invalid ISO-8601 time %q", s)
+	}
+	num := func(v string, def int) int {
+		if v == "" {
+			return def
+		}
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	year, err := strconv.Atoi(m[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("godruid: invalid ISO-8601 time %q", s)
+	}
+	month, day, hour, min, sec := num(m[2], 1), num(m[3], 1), num(m[4], 0), num(m[5], 0), num(m[6], 0)
+	nsec := 0
+	if m[7] != "" {
+		nsec = num((m[7] + "00000000")[:9], 0)
+	}
+	if month < 1 || month > 12 || day < 1 || day > daysIn(year, time.Month(month)) || hour > 23 || min > 59 || sec > 59 {
+		return time.Time{}, fmt.Errorf("godruid: invalid ISO-8601 time %q", s)
+	}
+
+	loc := time.UTC
+	if zone := m[8]; zone != "" && zone != "Z" {
+		digits := strings.Replace(zone[1:], ":", "", 1)
+		offset := num(digits[:2], 0)*3600 + num(digits[2:], 0)*60
+		if zone[0] == '-' {
+			offset = -offset
+		}
+		loc = time.FixedZone("", offset)
+	}
+	return time.Date(year, time.Month(month), day, hour, min, sec, nsec, loc), nil
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func (i Interval) String() string {
+	return i.Start.Format(IntervalTimeFormat) + "/" + i.End.Format(IntervalTimeFormat)
+}
+
+func (i Interval) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.String())
+}
+
+func (i *Interval) UnmarshalJSON(data []byte) (err error) {
+	var s string
+	if err = json.Unmarshal(data, &s); err != nil {
+		return
+	}
+	*i, err = ParseInterval(s)
+	return
+}
+
+func (i Interval) Duration() time.Duration {
+	return i.End.Sub(i.Start)
+}
+
+func (i Interval) IsEmpty() bool {
+	return !i.End.After(i.Start)
+}
+
+func (i Interval) Contains(t time.Time) bool {
+	return !t.Before(i.Start) && t.Before(i.End)
+}
+
+func (i Interval) Overlaps(o Interval) bool {
+	return i.Start.Before(o.End) && o.Start.Before(i.End)
+}
+
+// Abuts reports whether one interval ends right where the other starts.
+func (i Interval) Abuts(o Interval) bool {
+	return i.End.Equal(o.Start) || o.End.Equal(i.Start)
+}
+
+// Intersect returns the common part of i and o, ok is false if they don't overlap.
+func (i Interval) Intersect(o Interval) (r Interval, ok bool) {
+	if !i.Overlaps(o) {
+		return r, false
+	}
+	r = i
+	if o.Start.After(r.Start) {
+		r.Start = o.Start
+	}
+	if o.End.Before(r.End) {
+		r.End = o.End
+	}
+	return r, true
+}
+
+// Union returns the interval covering both i and o, ok is false if they
+// neither overlap nor abut, as the union would not be a single interval.
+func (i Interval) Union(o Interval) (r Interval, ok bool) {
+	if !i.Overlaps(o) && !i.Abuts(o) {
+		return r, false
+	}
+	r = i
+	if o.Start.Before(r.Start) {
+		r.Start = o.Start
+	}
+	if o.End.After(r.End) {
+		r.End = o.End
+	}
+	return r, true
+}
+
+// Split splits the interval into n pieces of the same length, the last one takes the remainder.
+func (i Interval) Split(n int) Intervals {
+	if n <= 1 || i.IsEmpty() {
+		return Intervals{i}
+	}
+	step := i.Duration() / time.Duration(n)
+	if step <= 0 {
+		return Intervals{i}
+	}
+	res := make(Intervals, 0, n)
+	start := i.Start
+	for k := 0; k < n-1; k++ {
+		end := start.Add(step)
+		res = append(res, Interval{start, end})
+		start = end
+	}
+	return append(res, Interval{start, i.End})
+}
+
+// SplitBy splits the interval into chunks of period p counting from Start, the last chunk is cut at End.
+func (i Interval) SplitBy(p Period) Intervals {
+	if p.IsZero() || i.IsEmpty() {
+		return Intervals{i}
+	}
+	var res Intervals
+	for k := 0; ; k++ {
+		start := p.AddTo(i.Start, k)
+		if !start.Before(i.End) {
+			break
+		}
+		end := p.AddTo(i.Start, k+1)
+		if end.After(i.End) {
+			end = i.End
+		}
+		res = append(res, Interval{start, end})
+	}
+	return res
+}
+
+// Normalize returns the sorted intervals with the overlapping or abutting ones merged
+// and the empty ones removed.
+func (is Intervals) Normalize() Intervals {
+	sorted := make(Intervals, 0, len(is))
+	for _, i := range is {
+		if !i.IsEmpty() {
+			sorted = append(sorted, i)
+		}
+	}
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Start.Before(sorted[b].Start) })
+
+	var res Intervals
+	for _, i := range sorted {
+		if n := len(res); n > 0 {
+			if u, ok := res[n-1].Union(i); ok {
+				res[n-1] = u
+				continue
+			}
+		}
+		res = append(res, i)
+	}
+	return res
+}
+
+// Intersect returns the parts of the intervals inside o.
+func (is Intervals) Intersect(o Interval) Intervals {
+	var res Intervals
+	for _, i := range is {
+		if r, ok := i.Intersect(o); ok {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// Span returns the smallest interval covering all the intervals.
+func (is Intervals) Span() (r Interval) {
+	for k, i := range is {
+		if k == 0 || i.Start.Before(r.Start) {
+			r.Start = i.Start
+		}
+		if k == 0 || i.End.After(r.End) {
+			r.End = i.End
+		}
+	}
+	return
+}
+
+// Strings returns the ISO-8601 strings of the intervals, nil if is is nil.
+func (is Intervals) Strings() []string {
+	if is == nil {
+		return nil
+	}
+	res := make([]string, len(is))
+	for k, i := range is {
+		res[k] = i.String()
+	}
+	return res
+}
+
+func (is Intervals) Duration() (d time.Duration) {
+	for _, i := range is {
+		d += i.Duration()
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package godruid
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	Convey("TestParsePeriod", t, func() {
+		p, err := ParsePeriod("P1Y2M3W4DT5H6M7.5S")
+		So(err, ShouldEqual, nil)
+		So(p, ShouldResemble, Period{Years: 1, Months: 2, Weeks: 3, Days: 4, Time: 5*time.Hour + 6*time.Minute + 7500*time.Millisecond})
+		So(p.String(), ShouldEqual, "P1Y2M3W4DT5H6M7.5S")
+
+		So(MustParsePeriod("PT6H").String(), ShouldEqual, "PT6H")
+		for _, s := range []string{"", "P", "PT", "P1DT", "1D", "P1H"} {
+			_, err = ParsePeriod(s)
+			So(err, ShouldNotEqual, nil)
+		}
+
+		jan31 := time.Date(2015, 1, 31, 0, 0, 0, 0, time.UTC)
+		So(MustParsePeriod("P1M").AddTo(jan31, 1), ShouldResemble, time.Date(2015, 2, 28, 0, 0, 0, 0, time.UTC))
+		So(MustParsePeriod("P1Y").AddTo(jan31, -1), ShouldResemble, time.Date(2014, 1, 31, 0, 0, 0, 0, time.UTC))
+	})
+}
+
+func TestInterval(t *testing.T) {
+	Convey("TestInterval", t, func() {
+		day := func(d int) time.Time { return time.Date(2015, 1, d, 0, 0, 0, 0, time.UTC) }
+
+		i := MustParseInterval("2015-01-01/2015-01-03T00:00:00Z")
+		So(i, ShouldResemble, Between(day(1), day(3)))
+		So(MustParseInterval("2015-01-01/P2D"), ShouldResemble, i)
+		So(MustParseInterval("P2D/2015-01-03"), ShouldResemble, i)
+		So(MustParseInterval("2015-01-01T08:00+08:00/P2D").Start.Equal(day(1)), ShouldBeTrue)
+		So(MustParseInterval("2015-01-01T08:00:00.000+0800/P2D").Start.Equal(day(1)), ShouldBeTrue)
+		So(MustParseInterval("2014-12-31T16-08/P2D").Start.Equal(day(1)), ShouldBeTrue)
+		eternity := "-146136543-09-08T08:23:32.096Z/146140482-04-24T15:36:27.903Z"
+		So(MustParseInterval(eternity).String(), ShouldEqual, eternity)
+		So(MustParseInterval(eternity).Start.UnixMilli(), ShouldEqual, int64(-1)<<62)
+		_, err := ParseInterval("2015-01-03/2015-01-01")
+		So(err, ShouldNotEqual, nil)
+
+		data, err := json.Marshal(Intervals{i})
+		So(err, ShouldEqual, nil)
+		So(string(data), ShouldEqual, `["2015-01-01T00:00:00.000Z/2015-01-03T00:00:00.000Z"]`)
+		So(Intervals{i}.Strings(), ShouldResemble, []string{"2015-01-01T00:00:00.000Z/2015-01-03T00:00:00.000Z"})
+		parsed, err := ParseIntervals([]string{"2015-01-01/P2D"})
+		So(err, ShouldEqual, nil)
+		So(parsed, ShouldResemble, Intervals{i})
+		var back Intervals
+		So(json.Unmarshal(data, &back), ShouldEqual, nil)
+		So(back[0].Start.Equal(i.Start) && back[0].End.Equal(i.End), ShouldBeTrue)
+
+		r, ok := Between(day(1), day(3)).Intersect(Between(day(2), day(5)))
+		So(ok, ShouldBeTrue)
+		So(r, ShouldResemble, Between(day(2), day(3)))
+		_, ok = Between(day(1), day(2)).Intersect(Between(day(2), day(5)))
+		So(ok, ShouldBeFalse)
+		r, ok = Between(day(1), day(2)).Union(Between(day(2), day(5)))
+		So(ok, ShouldBeTrue)
+		So(r, ShouldResemble, Between(day(1), day(5)))
+
+		So(Intervals{Between(day(4), day(6)), Between(day(1), day(2)), Between(day(2), day(3)), Between(day(5), day(7))}.Normalize(),
+			ShouldResemble, Intervals{Between(day(1), day(3)), Between(day(4), day(7))})
+
+		So(Between(day(1), day(4)).Split(3), ShouldResemble, Intervals{Between(day(1), day(2)), Between(day(2), day(3)), Between(day(3), day(4))})
+		So(Between(day(1), day(6)).SplitBy(MustParsePeriod("P2D")), ShouldResemble, Intervals{Between(day(1), day(3)), Between(day(3), day(5)), Between(day(5), day(6))})
+	})
+}
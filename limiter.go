@@ -0,0 +1,180 @@
+package godruid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// QueryLimits are the limits of a group of queries, the zero values mean unlimited.
+type QueryLimits struct {
+	Rate          float64 // The queries per second, by a token bucket.
+	Burst         int     // The size of the token bucket, 1 if 0.
+	MaxConcurrent int     // The max queries waiting for the broker at the same time.
+}
+
+// LimiterConfig are the limits of all the queries, and of the queries on specific
+// datasources or of specific types, e.g. "groupBy". A query has to satisfy all of
+// the limits which apply to it.
+type LimiterConfig struct {
+	Global      QueryLimits
+	DataSources map[string]QueryLimits
+	QueryTypes  map[string]QueryLimits
+}
+
+// Limiter limits the queries sent to the broker, the queries over the limits wait in
+// queue until they are allowed or their contexts are done. A query is failed at once if
+// it would have to wait beyond the deadline of its context.
+type Limiter struct {
+	global      *limitGroup
+	dataSources map[string]*limitGroup
+	queryTypes  map[string]*limitGroup
+}
+
+// ErrRateLimited is returned when a query would wait for the rate limit of Limiter beyond
+// the deadline of its context. It's also a context.DeadlineExceeded.
+var ErrRateLimited = errors.New("godruid: rate limited beyond the deadline")
+
+// QueueWaitRecorder is a MetricsRecorder which records how long the queries wait in the
+// queue of Limiter.
+type QueueWaitRecorder interface {
+	QueueWait(labels MetricLabels, wait time.Duration)
+}
+
+func NewLimiter(cfg LimiterConfig) *Limiter {
+	l := &Limiter{
+		global:      newLimitGroup(cfg.Global),
+		dataSources: make(map[string]*limitGroup, len(cfg.DataSources)),
+		queryTypes:  make(map[string]*limitGroup, len(cfg.QueryTypes)),
+	}
+	for name, limits := range cfg.DataSources {
+		l.dataSources[name] = newLimitGroup(limits)
+	}
+	for name, limits := range cfg.QueryTypes {
+		l.queryTypes[name] = newLimitGroup(limits)
+	}
+	return l
+}
+
+// acquire waits until the query is allowed, and returns the function to call when the
+// query is done. The most specific limits are acquired first, so a query waiting for
+// its datasource doesn't hold the global slots. If a group rejects the query, the rate
+// tokens taken from the groups before are given back.
+func (l *Limiter) acquire(ctx context.Context, queryType, dataSource string) (release func(), err error) {
+	var releases, refunds []func()
+	release = func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	for _, g := range []*limitGroup{l.queryTypes[queryType], l.dataSources[dataSource], l.global} {
+		if g == nil {
+			continue
+		}
+		r, refund, err := g.acquire(ctx)
+		if err != nil {
+			release()
+			for _, refund := range refunds {
+				refund()
+			}
+			return nil, err
+		}
+		releases = append(releases, r)
+		refunds = append(refunds, refund)
+	}
+	return release, nil
+}
+
+type limitGroup struct {
+	rate  float64
+	burst float64
+	slots chan struct{} // nil if the concurrency is unlimited.
+
+	mu     sync.Mutex
+	tokens float64 // Negative if the tokens are reserved by the waiting queries.
+	last   time.Time
+}
+
+func newLimitGroup(limits QueryLimits) *limitGroup {
+	g := &limitGroup{rate: limits.Rate, burst: float64(limits.Burst)}
+	if g.burst <= 0 {
+		g.burst = 1
+	}
+	g.tokens = g.burst
+	if limits.MaxConcurrent > 0 {
+		g.slots = make(chan struct{}, limits.MaxConcurrent)
+	}
+	return g
+}
+
+// acquire waits for a token and a slot, and returns the function to free the slot and the
+// function to give back the token if the query is not sent after all.
+func (g *limitGroup) acquire(ctx context.Context) (release, refund func(), err error) {
+	if err := g.waitToken(ctx); err != nil {
+		return nil, nil, err
+	}
+	if g.slots == nil {
+		return func() {}, g.refundToken, nil
+	}
+	select {
+	case g.slots <- struct{}{}:
+		return func() { <-g.slots }, g.refundToken, nil
+	case <-ctx.Done():
+		g.refundToken()
+		return nil, nil, ctx.Err()
+	}
+}
+
+// refundToken gives back a token taken by waitToken.
+func (g *limitGroup) refundToken() {
+	if g.rate <= 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.tokens++; g.tokens > g.burst {
+		g.tokens = g.burst
+	}
+}
+
+// waitToken takes a token of the rate limit, waiting for it if there is none.
+func (g *limitGroup) waitToken(ctx context.Context) error {
+	if g.rate <= 0 {
+		return nil
+	}
+	g.mu.Lock()
+	now := time.Now()
+	if !g.last.IsZero() {
+		g.tokens += now.Sub(g.last).Seconds() * g.rate
+		if g.tokens > g.burst {
+			g.tokens = g.burst
+		}
+	}
+	g.last = now
+	g.tokens--
+	var wait time.Duration
+	if g.tokens < 0 {
+		wait = time.Duration(-g.tokens / g.rate * float64(time.Second))
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+		g.tokens++
+		g.mu.Unlock()
+		return fmt.Errorf("%w: %w", ErrRateLimited, context.DeadlineExceeded)
+	}
+	g.mu.Unlock()
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		g.refundToken()
+		return ctx.Err()
+	}
+}
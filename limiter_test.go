@@ -0,0 +1,140 @@
+package godruid
+
+import (
+	"context"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type waitRecorder struct {
+	mu    sync.Mutex
+	waits []time.Duration
+}
+
+func (r *waitRecorder) QueryStarted(labels MetricLabels) {}
+func (r *waitRecorder) QueryDone(labels MetricLabels, duration time.Duration, responseBytes int, err error) {
+}
+func (r *waitRecorder) CacheLookup(labels MetricLabels, hit bool) {}
+func (r *waitRecorder) QueueWait(labels MetricLabels, wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.waits = append(r.waits, wait)
+}
+
+func TestLimiter(t *testing.T) {
+	Convey("TestLimiter", t, func() {
+		var running, maxRunning int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			w.Write([]byte(`[]`))
+		}))
+		defer server.Close()
+
+		newQuery := func(dataSource string) *QueryTimeseries {
+			return &QueryTimeseries{
+				DataSource:   dataSource,
+				Intervals:    []string{"2015-01-01/2015-01-02"},
+				Granularity:  GranAll,
+				Aggregations: []Aggregation{AggCount("count")},
+			}
+		}
+
+		Convey("concurrency", func() {
+			recorder := &waitRecorder{}
+			client := Client{
+				Url:     server.URL,
+				Metrics: recorder,
+				Limiter: NewLimiter(LimiterConfig{
+					Global:      QueryLimits{MaxConcurrent: 3},
+					DataSources: map[string]QueryLimits{"raw": {MaxConcurrent: 1}},
+				}),
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					client.Query(newQuery("raw"))
+				}()
+			}
+			wg.Wait()
+			So(atomic.LoadInt32(&maxRunning), ShouldEqual, 1)
+			So(len(recorder.waits), ShouldEqual, 4)
+
+			atomic.StoreInt32(&maxRunning, 0)
+			for i := 0; i < 6; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					client.Query(newQuery("events"))
+				}()
+			}
+			wg.Wait()
+			So(atomic.LoadInt32(&maxRunning), ShouldEqual, 3)
+		})
+
+		Convey("rate", func() {
+			client := Client{
+				Url:     server.URL,
+				Limiter: NewLimiter(LimiterConfig{QueryTypes: map[string]QueryLimits{"timeseries": {Rate: 20, Burst: 2}}}),
+			}
+			start := time.Now()
+			for i := 0; i < 4; i++ {
+				So(client.Query(newQuery("events")), ShouldEqual, nil)
+			}
+			// 2 queries at once, and then one every 50ms.
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 80*time.Millisecond)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			client.Query(newQuery("events"))
+			err := client.QueryWithContext(ctx, newQuery("events"))
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			So(errors.Is(err, ErrRateLimited), ShouldBeTrue)
+			So(ErrorClass(err), ShouldEqual, "rate_limited")
+		})
+
+		Convey("refund the tokens of the rejected queries", func() {
+			shortCtx := func() context.Context {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				Reset(cancel)
+				return ctx
+			}
+			l := NewLimiter(LimiterConfig{
+				Global:     QueryLimits{Rate: 1},
+				QueryTypes: map[string]QueryLimits{"timeseries": {Rate: 1, Burst: 2, MaxConcurrent: 1}},
+			})
+			// The global token is taken, so the timeseries query is rejected by the global
+			// group after it gets the token of its query type.
+			release, err := l.acquire(context.Background(), "groupBy", "")
+			So(err, ShouldEqual, nil)
+			release()
+			_, err = l.acquire(shortCtx(), "timeseries", "")
+			So(errors.Is(err, ErrRateLimited), ShouldBeTrue)
+
+			g := l.queryTypes["timeseries"]
+			release, _, err = g.acquire(shortCtx())
+			So(err, ShouldEqual, nil)
+			// The second token is given back when waiting for the slot is canceled.
+			_, _, err = g.acquire(shortCtx())
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			release()
+			_, _, err = g.acquire(shortCtx())
+			So(err, ShouldEqual, nil)
+		})
+	})
+}
@@ -0,0 +1,110 @@
+package godruid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+// RedactedValue replaces the filter values redacted in the logs.
+const RedactedValue = "[REDACTED]"
+
+// The fields of the query JSON which are logged.
+type querySummary struct {
+	QueryType  string          `json:"queryType"`
+	DataSource json.RawMessage `json:"dataSource"`
+	Intervals  []string        `json:"intervals"`
+}
+
+// unquoteJSON returns the JSON string, or the JSON itself if it's not a string.
+func unquoteJSON(data json.RawMessage) string {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		return s
+	}
+	return string(data)
+}
+
+// logRequest logs the request to the broker, at the error level if it failed.
+func (c *Client) logRequest(ctx context.Context, trace *Trace) {
+	level, msg := slog.LevelInfo, "druid query"
+	if trace.Err != nil {
+		level, msg = slog.LevelError, "druid query failed"
+	}
+	if !c.Logger.Enabled(ctx, level) {
+		return
+	}
+
+	var summary querySummary
+	json.Unmarshal(trace.Request, &summary)
+	var dataSource interface{} = summary.DataSource
+	var name string
+	if json.Unmarshal(summary.DataSource, &name) == nil {
+		dataSource = name
+	}
+	attrs := []slog.Attr{
+		slog.String("queryType", summary.QueryType),
+		slog.Any("dataSource", dataSource),
+		slog.Any("intervals", summary.Intervals),
+		slog.String("queryId", trace.QueryId),
+		slog.String("url", trace.URL),
+		slog.Int("status", trace.Status),
+		slog.Int("attempt", trace.Attempt),
+		slog.Duration("duration", trace.Duration),
+		slog.Int("responseBytes", len(trace.Response)),
+	}
+	if trace.Err != nil {
+		attrs = append(attrs, slog.String("errorClass", ErrorClass(trace.Err)), slog.Any("error", trace.Err))
+	}
+	if c.Logger.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs, slog.String("request", string(c.redactRequest(trace.Request))))
+		response := trace.Response
+		if isSmile(response) {
+			response = smileText(response)
+		}
+		attrs = append(attrs, slog.String("response", string(response)))
+	}
+	c.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// redactRequest returns the request with the filter values redacted by LogRedact.
+func (c *Client) redactRequest(req []byte) []byte {
+	if c.LogRedact == nil {
+		return req
+	}
+	var query map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(req))
+	d.UseNumber()
+	if err := d.Decode(&query); err != nil {
+		return req
+	}
+	c.redactFilters(query, false)
+	redacted, err := json.Marshal(query)
+	if err != nil {
+		return req
+	}
+	return redacted
+}
+
+// redactFilters redacts the values of the filters in v, inFilter tells whether v is a
+// part of a filter. The filters could be nested in others, or in filtered aggregations.
+func (c *Client) redactFilters(v interface{}, inFilter bool) {
+	switch x := v.(type) {
+	case []interface{}:
+		for _, e := range x {
+			c.redactFilters(e, inFilter)
+		}
+	case map[string]interface{}:
+		if dim, ok := x["dimension"].(string); ok && inFilter && c.LogRedact(dim) {
+			for _, key := range []string{"value", "values", "pattern", "function", "lower", "upper", "query"} {
+				if _, ok := x[key]; ok {
+					x[key] = RedactedValue
+				}
+			}
+		}
+		for key, e := range x {
+			c.redactFilters(e, inFilter || key == "filter")
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package godruid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	Convey("TestLogger", t, func() {
+		server, _ := fakeBroker(map[string]string{
+			"2015-01-01": `[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":3}}]`,
+		})
+		defer server.Close()
+
+		var buf bytes.Buffer
+		client := Client{
+			Url:       server.URL,
+			Logger:    slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+			LogRedact: func(dimension string) bool { return dimension == "user" },
+		}
+		query := &QueryTimeseries{
+			DataSource:   "events",
+			Intervals:    []string{"2015-01-01/2015-01-02"},
+			Granularity:  GranAll,
+			Filter:       FilterAnd(FilterSelector("user", "alice"), FilterSelector("country", "cn")),
+			Aggregations: []Aggregation{AggCount("count")},
+			Context:      &QueryContext{QueryId: "q1"},
+		}
+		So(client.Query(query), ShouldEqual, nil)
+
+		var record map[string]interface{}
+		So(json.Unmarshal(buf.Bytes(), &record), ShouldEqual, nil)
+		So(record["level"], ShouldEqual, "INFO")
+		So(record["queryType"], ShouldEqual, "timeseries")
+		So(record["dataSource"], ShouldEqual, "events")
+		So(record["queryId"], ShouldEqual, "q1")
+		So(record["status"], ShouldEqual, 200)
+		request := record["request"].(string)
+		So(request, ShouldNotContainSubstring, "alice")
+		So(request, ShouldContainSubstring, RedactedValue)
+		So(request, ShouldContainSubstring, `"cn"`)
+
+		Convey("retries", func() {
+			buf.Reset()
+			retryOnce := func(next QueryFunc) QueryFunc {
+				return func(ctx context.Context, query Query, req []byte) ([]byte, error) {
+					next(ctx, query, req)
+					return next(NextAttempt(ctx), query, req)
+				}
+			}
+			client.Interceptors = []Interceptor{retryOnce}
+			So(client.Query(query), ShouldEqual, nil)
+			d := json.NewDecoder(&buf)
+			for _, attempt := range []int{1, 2} {
+				So(d.Decode(&record), ShouldEqual, nil)
+				So(record["attempt"], ShouldEqual, attempt)
+			}
+		})
+
+		Convey("failed queries", func() {
+			buf.Reset()
+			client.Url = "http://127.0.0.1:1"
+			client.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+			So(client.Query(query), ShouldNotEqual, nil)
+			So(json.Unmarshal(buf.Bytes(), &record), ShouldEqual, nil)
+			So(record["level"], ShouldEqual, "ERROR")
+			So(record["errorClass"], ShouldEqual, "network")
+			So(strings.Contains(buf.String(), `"request"`), ShouldBeFalse)
+		})
+
+		Convey("Druid errors", func() {
+			errServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"error":"Query timeout","errorMessage":"timed out","errorClass":"java.util.concurrent.TimeoutException","host":"h1"}`))
+			}))
+			defer errServer.Close()
+			client.Url = errServer.URL
+			err := client.Query(query)
+			druidErr, ok := err.(*DruidError)
+			So(ok, ShouldBeTrue)
+			So(druidErr.StatusCode, ShouldEqual, 500)
+			So(druidErr.ErrorMessage, ShouldEqual, "timed out")
+			So(ErrorClass(err), ShouldEqual, "Query timeout")
+		})
+
+		Convey("decode errors", func() {
+			badServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"not":"array"}`))
+			}))
+			defer badServer.Close()
+			buf.Reset()
+			client.Url = badServer.URL
+			So(client.Query(query), ShouldNotEqual, nil)
+			So(json.Unmarshal(buf.Bytes(), &record), ShouldEqual, nil)
+			So(record["level"], ShouldEqual, "ERROR")
+			So(record["status"], ShouldEqual, 200)
+			So(record["errorClass"], ShouldEqual, "decode")
+		})
+
+		Convey("rate limited queries", func() {
+			client.Limiter = NewLimiter(LimiterConfig{Global: QueryLimits{Rate: 1, Burst: 1}})
+			So(client.Query(query), ShouldEqual, nil)
+			buf.Reset()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			So(client.QueryWithContext(ctx, query), ShouldNotEqual, nil)
+			So(json.Unmarshal(buf.Bytes(), &record), ShouldEqual, nil)
+			So(record["level"], ShouldEqual, "ERROR")
+			So(record["status"], ShouldEqual, 0)
+			So(record["errorClass"], ShouldEqual, "rate_limited")
+		})
+
+		Convey("error classes", func() {
+			So(ErrorClass(&IncompleteResultError{Meta: &ResponseMeta{}}), ShouldEqual, "incomplete")
+			So(ErrorClass(query.onResponse([]byte(`[{"timestamp":}]`))), ShouldEqual, "decode")
+			So(ErrorClass(query.onResponse([]byte(`{}`))), ShouldEqual, "decode")
+		})
+	})
+}
@@ -0,0 +1,119 @@
+package godruid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ResponseMeta is the metadata of a response from the broker.
+type ResponseMeta struct {
+	QueryId string // X-Druid-Query-Id.
+	ETag    string // The result-level cache ETag, for If-None-Match.
+
+	// Context is X-Druid-Response-Context, the fields known are parsed below.
+	Context                      map[string]interface{}
+	UncoveredIntervals           Intervals
+	UncoveredIntervalsOverflowed bool
+	MissingSegments              []SegmentDescriptor
+
+	// NotModified tells that the broker answered 304 Not Modified to If-None-Match,
+	// and the result is the cached one.
+	NotModified bool
+	// Cached tells that the result is from the Cache of Client without a request.
+	Cached bool
+}
+
+// SegmentDescriptor is a segment in the response context, e.g. a missing one.
+type SegmentDescriptor struct {
+	Interval  Interval `json:"itvl"`
+	Version   string   `json:"ver"`
+	Partition int      `json:"part"`
+}
+
+// Incomplete reports whether some data of the queried intervals is missing in the result.
+func (m *ResponseMeta) Incomplete() bool {
+	return m != nil && (len(m.UncoveredIntervals) > 0 || m.UncoveredIntervalsOverflowed || len(m.MissingSegments) > 0)
+}
+
+// IncompleteResultError is returned when Client.FailOnIncomplete is set and the broker
+// tells that some data is missing.
+type IncompleteResultError struct {
+	Meta *ResponseMeta
+}
+
+func (e *IncompleteResultError) Error() string {
+	return fmt.Sprintf("godruid: incomplete result of query %s, uncovered intervals: %v, missing segments: %d",
+		e.Meta.QueryId, e.Meta.UncoveredIntervals, len(e.Meta.MissingSegments))
+}
+
+func parseResponseMeta(header http.Header) (*ResponseMeta, error) {
+	meta := &ResponseMeta{
+		QueryId: header.Get("X-Druid-Query-Id"),
+		ETag:    header.Get("ETag"),
+	}
+	raw := header.Get("X-Druid-Response-Context")
+	if raw == "" {
+		return meta, nil
+	}
+	var ctx struct {
+		UncoveredIntervals           Intervals           `json:"uncoveredIntervals"`
+		UncoveredIntervalsOverflowed bool                `json:"uncoveredIntervalsOverflowed"`
+		MissingSegments              []SegmentDescriptor `json:"missingSegments"`
+		ETag                         string              `json:"ETag"`
+	}
+	if err := json.Unmarshal([]byte(raw), &meta.Context); err != nil {
+		return nil, fmt.Errorf("godruid: invalid response context: %w", err)
+	}
+	if err := json.Unmarshal([]byte(raw), &ctx); err != nil {
+		return nil, fmt.Errorf("godruid: invalid response context: %w", err)
+	}
+	meta.UncoveredIntervals = ctx.UncoveredIntervals
+	meta.UncoveredIntervalsOverflowed = ctx.UncoveredIntervalsOverflowed
+	meta.MissingSegments = ctx.MissingSegments
+	if meta.ETag == "" {
+		meta.ETag = ctx.ETag
+	}
+	return meta, nil
+}
+
+// responseExchange passes the ETag down to the request and the ResponseMeta up from it,
+// through the context. The fingerprint of the query is kept in it once computed.
+type responseExchange struct {
+	ifNoneMatch string
+	meta        *ResponseMeta
+	fingerprint string
+}
+
+type responseExchangeKey struct{}
+
+// withResponseExchange returns ctx with a new responseExchange.
+func withResponseExchange(ctx context.Context) (context.Context, *responseExchange) {
+	x := &responseExchange{}
+	return context.WithValue(ctx, responseExchangeKey{}, x), x
+}
+
+func responseExchangeFrom(ctx context.Context) *responseExchange {
+	x, _ := ctx.Value(responseExchangeKey{}).(*responseExchange)
+	return x
+}
+
+// ---------------------------------
+// ETags
+// ---------------------------------
+
+// The ETag entries are the ETags and the responses separated by a new line.
+func etagEntry(etag string, result []byte) []byte {
+	return append([]byte(etag+"\n"), result...)
+}
+
+func parseETagEntry(entry []byte) (etag string, result []byte, ok bool) {
+	s := string(entry)
+	i := strings.IndexByte(s, '\n')
+	if i <= 0 {
+		return "", nil, false
+	}
+	return s[:i], entry[i+1:], true
+}
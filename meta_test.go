@@ -0,0 +1,92 @@
+package godruid
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeCache is a Cache which ignores the TTLs, for expiring the entries by hand.
+type fakeCache map[string][]byte
+
+func (c fakeCache) Get(key string) ([]byte, bool)                   { v, ok := c[key]; return v, ok }
+func (c fakeCache) Set(key string, value []byte, ttl time.Duration) { c[key] = value }
+
+func TestResponseMeta(t *testing.T) {
+	Convey("TestResponseMeta", t, func() {
+		responseContext := `{"uncoveredIntervals":["2015-01-01T12:00:00.000Z/2015-01-02T00:00:00.000Z"],"uncoveredIntervalsOverflowed":false,` +
+			`"missingSegments":[{"itvl":"2015-01-01T00:00:00.000Z/2015-01-01T01:00:00.000Z","ver":"v1","part":2}]}`
+		var ifNoneMatch string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ifNoneMatch = r.Header.Get("If-None-Match")
+			w.Header().Set("X-Druid-Query-Id", "q1")
+			w.Header().Set("ETag", "e1")
+			w.Header().Set("X-Druid-Response-Context", responseContext)
+			if ifNoneMatch == "e1" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte(`[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":3}}]`))
+		}))
+		defer server.Close()
+
+		query := &QueryTimeseries{
+			DataSource:   "events",
+			Intervals:    []string{"2015-01-01/2015-01-02"},
+			Granularity:  GranAll,
+			Aggregations: []Aggregation{AggCount("count")},
+			Context:      &QueryContext{UncoveredIntervalsLimit: 10},
+		}
+		client := Client{Url: server.URL}
+		So(client.Query(query), ShouldEqual, nil)
+		meta := query.ResponseMeta
+		So(meta.QueryId, ShouldEqual, "q1")
+		So(meta.ETag, ShouldEqual, "e1")
+		So(meta.UncoveredIntervals, ShouldResemble, Intervals{MustParseInterval("2015-01-01T12:00/2015-01-02")})
+		So(meta.MissingSegments, ShouldResemble, []SegmentDescriptor{
+			{Interval: MustParseInterval("2015-01-01T00:00/2015-01-01T01:00"), Version: "v1", Partition: 2}})
+		So(meta.Incomplete(), ShouldBeTrue)
+
+		client.FailOnIncomplete = true
+		_, ok := client.Query(query).(*IncompleteResultError)
+		So(ok, ShouldBeTrue)
+
+		Convey("incomplete results are not cached", func() {
+			cache := fakeCache{}
+			client := Client{Url: server.URL, Cache: cache, CacheETags: true}
+			So(client.Query(query), ShouldEqual, nil)
+			So(client.Query(query), ShouldEqual, nil)
+			So(query.ResponseMeta.Cached, ShouldBeFalse)
+
+			query.Granularity = GranDay
+			So(client.QueryIncremental(context.Background(), query), ShouldEqual, nil)
+			So(len(cache), ShouldEqual, 0)
+		})
+
+		Convey("ETags", func() {
+			responseContext = `{}`
+			cache := fakeCache{}
+			client := Client{Url: server.URL, Cache: cache, CacheETags: true}
+			So(client.Query(query), ShouldEqual, nil)
+			So(ifNoneMatch, ShouldEqual, "")
+
+			So(client.Query(query), ShouldEqual, nil)
+			So(query.ResponseMeta.Cached, ShouldBeTrue)
+
+			// Expire the response but keep the ETag.
+			for key := range cache {
+				if len(key) == 64 {
+					delete(cache, key)
+				}
+			}
+			query.QueryResult = nil
+			So(client.Query(query), ShouldEqual, nil)
+			So(ifNoneMatch, ShouldEqual, "e1")
+			So(query.ResponseMeta.NotModified, ShouldBeTrue)
+			So(len(query.QueryResult), ShouldEqual, 1)
+		})
+	})
+}
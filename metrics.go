@@ -0,0 +1,40 @@
+package godruid
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MetricLabels are the labels of the metrics of a query.
+type MetricLabels struct {
+	QueryType  string
+	DataSource string
+	Broker     string // The URL of the broker.
+}
+
+// MetricsRecorder records the metrics of the queries, see the druidprom package for
+// the Prometheus one. The MetricsRecorder of a Client shared by goroutines is called
+// concurrently.
+type MetricsRecorder interface {
+	// QueryStarted is called before a request is sent to the broker, after waiting for
+	// the Limiter. The queries rejected by the Limiter are started and done at once.
+	QueryStarted(labels MetricLabels)
+	// QueryDone is called when a request started is done, err is nil if it succeeded and
+	// its response is decoded into the query. ErrorClass tells the class of err.
+	QueryDone(labels MetricLabels, duration time.Duration, responseBytes int, err error)
+	// CacheLookup is called when the Cache of Client is looked up.
+	CacheLookup(labels MetricLabels, hit bool)
+}
+
+// RetryRecorder is a MetricsRecorder which records the retries of the queries. QueryRetried
+// is called before a retry is sent, i.e. a request sent with NextAttempt by an interceptor.
+type RetryRecorder interface {
+	QueryRetried(labels MetricLabels)
+}
+
+// metricLabels returns the labels of the query JSON.
+func (c *Client) metricLabels(req []byte) MetricLabels {
+	var summary querySummary
+	json.Unmarshal(req, &summary)
+	return MetricLabels{QueryType: summary.QueryType, DataSource: unquoteJSON(summary.DataSource), Broker: c.Url}
+}
@@ -0,0 +1,125 @@
+package godruid
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Period is an ISO-8601 period like "P1D", "PT6H" or "P1Y2M".
+// The date parts are calendar based, e.g. "P1M" is one month whatever the days of the month are.
+type Period struct {
+	Years  int
+	Months int
+	Weeks  int
+	Days   int
+	Time   time.Duration // The hours, minutes and seconds part.
+}
+
+var periodRegexp = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+
+func ParsePeriod(s string) (p Period, err error) {
+	m := periodRegexp.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return p, fmt.Errorf("godruid: invalid ISO-8601 period %q", s)
+	}
+	atoi := func(v string) int {
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	p.Years = atoi(m[1])
+	p.Months = atoi(m[2])
+	p.Weeks = atoi(m[3])
+	p.Days = atoi(m[4])
+	p.Time = time.Duration(atoi(m[5]))*time.Hour + time.Duration(atoi(m[6]))*time.Minute
+	if m[7] != "" {
+		secs, err := strconv.ParseFloat(strings.Replace(m[7], ",", ".", 1), 64)
+		if err != nil {
+			return p, fmt.Errorf("godruid: invalid ISO-8601 period %q", s)
+		}
+		p.Time += time.Duration(secs * float64(time.Second))
+	}
+	return p, nil
+}
+
+func MustParsePeriod(s string) Period {
+	p, err := ParsePeriod(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// PeriodOf returns the period of a fixed duration, e.g. "PT1H" for time.Hour.
+func PeriodOf(d time.Duration) Period {
+	return Period{Time: d}
+}
+
+func (p Period) IsZero() bool {
+	return p == Period{}
+}
+
+// IsFixed reports whether the period has the same length wherever it starts,
+// i.e. it has no calendar parts.
+func (p Period) IsFixed() bool {
+	return p.Years == 0 && p.Months == 0 && p.Weeks == 0 && p.Days == 0
+}
+
+// AddTo adds the period n times to t, n could be negative.
+// Like Druid, adding months keeps the day in the target month, e.g. 01-31 plus "P1M" is 02-28.
+func (p Period) AddTo(t time.Time, n int) time.Time {
+	if months := n * (p.Years*12 + p.Months); months != 0 {
+		y, m, d := t.Date()
+		first := time.Date(y, m+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		if last := first.AddDate(0, 1, -1).Day(); d > last {
+			d = last
+		}
+		t = first.AddDate(0, 0, d-1)
+	}
+	if days := n * (p.Weeks*7 + p.Days); days != 0 {
+		t = t.AddDate(0, 0, days)
+	}
+	return t.Add(time.Duration(n) * p.Time)
+}
+
+func (p Period) String() string {
+	if p.IsZero() {
+		return "PT0S"
+	}
+	var b strings.Builder
+	b.WriteString("P")
+	write := func(n int, unit string) {
+		if n != 0 {
+			b.WriteString(strconv.Itoa(n))
+			b.WriteString(unit)
+		}
+	}
+	write(p.Years, "Y")
+	write(p.Months, "M")
+	write(p.Weeks, "W")
+	write(p.Days, "D")
+	if p.Time != 0 {
+		b.WriteString("T")
+		hours := p.Time / time.Hour
+		minutes := (p.Time % time.Hour) / time.Minute
+		secs := p.Time % time.Minute
+		write(int(hours), "H")
+		write(int(minutes), "M")
+		if secs != 0 {
+			b.WriteString(strconv.FormatFloat(secs.Seconds(), 'f', -1, 64))
+			b.WriteString("S")
+		}
+	}
+	return b.String()
+}
+
+func (p Period) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Period) UnmarshalText(text []byte) (err error) {
+	*p, err = ParsePeriod(string(text))
+	return
+}
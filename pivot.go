@@ -0,0 +1,219 @@
+package godruid
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Point is a point of a time series.
+type Point struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// Pivot turns the values of dimension into columns, with the values of metric as the cells.
+// The columns are the timestamp, the other dimensions, and then the dimension values sorted.
+// There is a row for every timestamp and combination of the other dimensions, in the order
+// they appear in QueryResult, and the cells without results are nil.
+func (q *QueryGroupBy) Pivot(dimension, metric string) (*ResultTable, error) {
+	var others []DimSpec
+	for _, dim := range q.Dimensions {
+		if dimOutputName(dim) != dimension {
+			others = append(others, dim)
+		}
+	}
+	if len(others) == len(q.Dimensions) {
+		return nil, fmt.Errorf("godruid: %q is not a dimension of the query", dimension)
+	}
+	t := newResultTable(true, others, nil, nil)
+	fixed := len(t.Columns)
+
+	var values []string
+	valueSeen := map[string]bool{}
+	for _, item := range q.QueryResult {
+		if v := fmt.Sprint(nilToEmpty(item.Event[dimension])); !valueSeen[v] {
+			valueSeen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	valueIndex := make(map[string]int, len(values))
+	typ := q.metricType(metric)
+	for i, v := range values {
+		t.addColumn(v, ColumnAggregation, typ)
+		valueIndex[v] = fixed + i
+	}
+
+	rowIndex := map[string]int{}
+	for _, item := range q.QueryResult {
+		key := item.Timestamp + "\x01" + dimsKey(item.Event, others)
+		i, ok := rowIndex[key]
+		if !ok {
+			i = len(t.Rows)
+			rowIndex[key] = i
+			if err := t.addRow(item.Timestamp, item.Event); err != nil {
+				return nil, err
+			}
+			for j := fixed; j < len(t.Columns); j++ {
+				t.Rows[i][j] = nil
+			}
+		}
+		t.Rows[i][valueIndex[fmt.Sprint(nilToEmpty(item.Event[dimension]))]] = item.Event[metric]
+	}
+	return t, nil
+}
+
+// Unpivot turns the metrics into rows, i.e. a row for every result and metric, with the
+// columns of the timestamp, the dimensions, "metric" and "value". All the aggregations and
+// post aggregations are unpivoted if metrics are not given.
+func (q *QueryGroupBy) Unpivot(metrics ...string) (*ResultTable, error) {
+	if len(metrics) == 0 {
+		for _, agg := range q.Aggregations {
+			metrics = append(metrics, agg.Name)
+		}
+		for _, pa := range q.PostAggregations {
+			metrics = append(metrics, pa.Name)
+		}
+	}
+	t := newResultTable(true, q.Dimensions, nil, nil)
+	var typ ValueType
+	for i, m := range metrics {
+		if i == 0 {
+			typ = q.metricType(m)
+		} else if q.metricType(m) != typ {
+			typ = ""
+		}
+	}
+	t.addColumn("metric", ColumnDimension, ValueString)
+	t.addColumn("value", ColumnAggregation, typ)
+
+	for _, item := range q.QueryResult {
+		for _, m := range metrics {
+			event := copyRow(item.Event)
+			event["metric"] = m
+			event["value"] = item.Event[m]
+			if err := t.addRow(item.Timestamp, event); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t, nil
+}
+
+// Series returns the time series of metric for every value of dimension, sorted by time.
+// The results without the metric value, i.e. nil, are skipped.
+func (q *QueryGroupBy) Series(dimension, metric string) (map[string][]Point, error) {
+	series := map[string][]Point{}
+	for _, item := range q.QueryResult {
+		v := item.Event[metric]
+		if v == nil {
+			continue
+		}
+		f, ok := toFloat64(v)
+		if !ok {
+			return nil, fmt.Errorf("godruid: %q of %v is not a number", metric, v)
+		}
+		t, err := parseISOTime(item.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		key := fmt.Sprint(nilToEmpty(item.Event[dimension]))
+		series[key] = append(series[key], Point{Timestamp: t, Value: f})
+	}
+	for _, points := range series {
+		sort.SliceStable(points, func(a, b int) bool { return points[a].Timestamp.Before(points[b].Timestamp) })
+	}
+	return series, nil
+}
+
+// Subtotals re-aggregates the results grouped by the given dimensions, and recomputes the
+// post aggregations. Put "timestamp" into dims to group by time as well, or else the rows
+// take the earliest timestamp of their groups. The aggregations must be combinable, i.e.
+// count, sums, mins or maxes.
+func (q *QueryGroupBy) Subtotals(dims ...string) ([]GroupbyItem, error) {
+	if err := checkMergeable(q.Aggregations, q.PostAggregations, nil); err != nil {
+		return nil, err
+	}
+	byTime := false
+	groupDims := make([]DimSpec, 0, len(dims))
+	for _, dim := range dims {
+		if dim == "timestamp" {
+			byTime = true
+		} else {
+			groupDims = append(groupDims, dim)
+		}
+	}
+
+	var res []GroupbyItem
+	index := map[string]int{}
+	for _, item := range q.QueryResult {
+		key := dimsKey(item.Event, groupDims)
+		if byTime {
+			key = item.Timestamp + "\x01" + key
+		}
+		if i, ok := index[key]; ok {
+			combineRow(res[i].Event, item.Event, q.Aggregations)
+			if timestampBefore(item.Timestamp, res[i].Timestamp) {
+				res[i].Timestamp = item.Timestamp
+			}
+			continue
+		}
+		event := make(Event, len(groupDims)+len(q.Aggregations)+len(q.PostAggregations))
+		for _, dim := range dims {
+			if dim != "timestamp" {
+				event[dim] = item.Event[dim]
+			}
+		}
+		combineRow(event, item.Event, q.Aggregations)
+		index[key] = len(res)
+		res = append(res, GroupbyItem{Version: item.Version, Timestamp: item.Timestamp, Event: event})
+	}
+	for _, item := range res {
+		if err := computePostAggs(item.Event, q.PostAggregations); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Totals re-aggregates all the results into one, check Subtotals for the details.
+func (q *QueryGroupBy) Totals() (Event, error) {
+	res, err := q.Subtotals()
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return res[0].Event, nil
+}
+
+// metricType returns the value type of the aggregation or post aggregation.
+func (q *QueryGroupBy) metricType(name string) ValueType {
+	for _, agg := range q.Aggregations {
+		if agg.Name == name {
+			return agg.valueType()
+		}
+	}
+	for _, pa := range q.PostAggregations {
+		if pa.Name == name {
+			return pa.valueType()
+		}
+	}
+	return ""
+}
+
+// dimsKey returns the key of the values of dims in event.
+func dimsKey(event map[string]interface{}, dims []DimSpec) string {
+	values := make([]string, len(dims))
+	for i, dim := range dims {
+		values[i] = fmt.Sprint(event[dimOutputName(dim)])
+	}
+	return strings.Join(values, "\x00")
+}
+
+func nilToEmpty(v interface{}) interface{} {
+	if v == nil {
+		return ""
+	}
+	return v
+}
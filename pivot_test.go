@@ -0,0 +1,50 @@
+package godruid
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestPivot(t *testing.T) {
+	Convey("TestPivot", t, func() {
+		query := &QueryGroupBy{
+			Dimensions:   []DimSpec{"country", "os"},
+			Aggregations: []Aggregation{AggLongSum("bytes", "bytes")},
+			QueryResult: []GroupbyItem{
+				{Timestamp: "2015-01-01T00:00:00.000Z", Event: Event{"country": "us", "os": "ios", "bytes": json.Number("1")}},
+				{Timestamp: "2015-01-01T00:00:00.000Z", Event: Event{"country": "cn", "os": "ios", "bytes": json.Number("2")}},
+				{Timestamp: "2015-01-02T00:00:00.000Z", Event: Event{"country": "cn", "os": "ios", "bytes": json.Number("3")}},
+			},
+		}
+		day := func(d int) time.Time { return time.Date(2015, 1, d, 0, 0, 0, 0, time.UTC) }
+
+		table, err := query.Pivot("country", "bytes")
+		So(err, ShouldEqual, nil)
+		So(len(table.Columns), ShouldEqual, 4)
+		So(table.Columns[3], ShouldResemble, TableColumn{"us", ColumnAggregation, ValueLong})
+		So(table.Rows, ShouldResemble, [][]interface{}{
+			{day(1), "ios", json.Number("2"), json.Number("1")},
+			{day(2), "ios", json.Number("3"), nil},
+		})
+
+		table, err = query.Unpivot()
+		So(err, ShouldEqual, nil)
+		So(len(table.Rows), ShouldEqual, 3)
+		So(table.Rows[0], ShouldResemble, []interface{}{day(1), "us", "ios", "bytes", json.Number("1")})
+
+		series, err := query.Series("country", "bytes")
+		So(err, ShouldEqual, nil)
+		So(series["cn"], ShouldResemble, []Point{{day(1), 2}, {day(2), 3}})
+
+		subtotals, err := query.Subtotals("timestamp")
+		So(err, ShouldEqual, nil)
+		So(len(subtotals), ShouldEqual, 2)
+		So(subtotals[0].Event, ShouldResemble, Event{"bytes": json.Number("3")})
+
+		totals, err := query.Totals()
+		So(err, ShouldEqual, nil)
+		So(totals, ShouldResemble, Event{"bytes": json.Number("6")})
+	})
+}
@@ -2,6 +2,7 @@ package godruid
 
 import (
 	"encoding/json"
+	"fmt"
 )
 
 type PostAggregation struct {
@@ -48,6 +49,79 @@ func (pa PostAggregation) GetReferAggs(parentName ...string) (refers []AggRefer)
 	return
 }
 
+// valueType returns the type of the computed values, or empty if it is not known.
+func (pa PostAggregation) valueType() ValueType {
+	switch pa.Type {
+	case "arithmetic", "javascript", "hyperUniqueCardinality", "constant":
+		return ValueDouble
+	}
+	return ""
+}
+
+// computable reports whether the post aggregation could be computed on client side.
+func (pa PostAggregation) computable() bool {
+	switch pa.Type {
+	case "arithmetic":
+		for _, f := range pa.Fields {
+			if !f.computable() {
+				return false
+			}
+		}
+		return true
+	case "fieldAccess", "finalizingFieldAccess", "constant":
+		return true
+	}
+	return false
+}
+
+// compute computes the post aggregation with the aggregated values in row, the same as Druid does.
+func (pa PostAggregation) compute(row map[string]interface{}) (float64, error) {
+	switch pa.Type {
+	case "constant":
+		v, _ := toFloat64(pa.Value)
+		return v, nil
+	case "fieldAccess", "finalizingFieldAccess":
+		v, ok := toFloat64(row[pa.FieldName])
+		if !ok && row[pa.FieldName] != nil {
+			return 0, fmt.Errorf("godruid: field %q of post aggregation is not a number", pa.FieldName)
+		}
+		return v, nil
+	case "arithmetic":
+		var res float64
+		for i, f := range pa.Fields {
+			v, err := f.compute(row)
+			if err != nil {
+				return 0, err
+			}
+			if i == 0 {
+				res = v
+				continue
+			}
+			switch pa.Fn {
+			case "+":
+				res += v
+			case "-":
+				res -= v
+			case "*":
+				res *= v
+			case "/":
+				// Druid returns 0 while dividing by 0.
+				if v == 0 {
+					res = 0
+				} else {
+					res /= v
+				}
+			case "quotient":
+				res /= v
+			default:
+				return 0, fmt.Errorf("godruid: unknown arithmetic fn %q", pa.Fn)
+			}
+		}
+		return res, nil
+	}
+	return 0, fmt.Errorf("godruid: post aggregation %q is not computable on client side", pa.Type)
+}
+
 func PostAggRawJson(rawJson string) PostAggregation {
 	pa := &PostAggregation{}
 	json.Unmarshal([]byte(rawJson), pa)
@@ -1,15 +1,25 @@
 package godruid
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 )
 
 // Check http://druid.io/docs/0.6.154/Querying.html#query-operators for detail description.
 
 // The Query interface stands for any kinds of druid query.
 type Query interface {
+	// Table returns the results as a ResultTable, which is the same for all kinds of queries.
+	Table() (*ResultTable, error)
+
 	setup()
 	onResponse(content []byte) error
+	getDataSource() string
+	getIntervals() []string
+	getContext() *QueryContext
+	setContext(ctx *QueryContext)
+	setResponseMeta(meta *ResponseMeta)
 }
 
 // ---------------------------------
@@ -17,31 +27,37 @@ type Query interface {
 // ---------------------------------
 
 type QueryGroupBy struct {
-	QueryType        string                 `json:"queryType"`
-	DataSource       string                 `json:"dataSource"`
-	Dimensions       []DimSpec              `json:"dimensions"`
-	Granularity      Granlarity             `json:"granularity"`
-	LimitSpec        *Limit                 `json:"limitSpec,omitempty"`
-	Having           *Having                `json:"having,omitempty"`
-	Filter           *Filter                `json:"filter,omitempty"`
-	Aggregations     []Aggregation          `json:"aggregations"`
-	PostAggregations []PostAggregation      `json:"postAggregations,omitempty"`
-	Intervals        []string               `json:"intervals"`
-	Context          map[string]interface{} `json:"context,omitempty"`
-
-	QueryResult []GroupbyItem `json:"-"`
+	QueryType        string            `json:"queryType"`
+	DataSource       string            `json:"dataSource"`
+	Dimensions       []DimSpec         `json:"dimensions"`
+	Granularity      Granlarity        `json:"granularity"`
+	LimitSpec        *Limit            `json:"limitSpec,omitempty"`
+	Having           *Having           `json:"having,omitempty"`
+	Filter           *Filter           `json:"filter,omitempty"`
+	Aggregations     []Aggregation     `json:"aggregations"`
+	PostAggregations []PostAggregation `json:"postAggregations,omitempty"`
+	Intervals        []string          `json:"intervals"`
+	Context          *QueryContext     `json:"context,omitempty"`
+
+	QueryResult  []GroupbyItem `json:"-"`
+	ResponseMeta *ResponseMeta `json:"-"`
 }
 
 type GroupbyItem struct {
-	Version   string                 `json:"version"`
-	Timestamp string                 `json:"timestamp"`
-	Event     map[string]interface{} `json:"event"`
+	Version   string `json:"version"`
+	Timestamp string `json:"timestamp"`
+	Event     Event  `json:"event"`
 }
 
-func (q *QueryGroupBy) setup() { q.QueryType = "groupBy" }
+func (q *QueryGroupBy) setup()                             { q.QueryType = "groupBy" }
+func (q *QueryGroupBy) getDataSource() string              { return q.DataSource }
+func (q *QueryGroupBy) getIntervals() []string             { return q.Intervals }
+func (q *QueryGroupBy) getContext() *QueryContext          { return q.Context }
+func (q *QueryGroupBy) setContext(ctx *QueryContext)       { q.Context = ctx }
+func (q *QueryGroupBy) setResponseMeta(meta *ResponseMeta) { q.ResponseMeta = meta }
 func (q *QueryGroupBy) onResponse(content []byte) error {
 	res := new([]GroupbyItem)
-	err := json.Unmarshal(content, res)
+	err := unmarshalResponse(content, res)
 	if err != nil {
 		return err
 	}
@@ -54,17 +70,18 @@ func (q *QueryGroupBy) onResponse(content []byte) error {
 // ---------------------------------
 
 type QuerySearch struct {
-	QueryType        string                 `json:"queryType"`
-	DataSource       string                 `json:"dataSource"`
-	Granularity      Granlarity             `json:"granularity"`
-	Filter           *Filter                `json:"filter,omitempty"`
-	Intervals        []string               `json:"intervals"`
-	SearchDimensions []string               `json:"searchDimensions,omitempty"`
-	Query            *SearchQuery           `json:"query"`
-	Sort             *SearchSort            `json:"sort"`
-	Context          map[string]interface{} `json:"context,omitempty"`
-
-	QueryResult []SearchItem `json:"-"`
+	QueryType        string        `json:"queryType"`
+	DataSource       string        `json:"dataSource"`
+	Granularity      Granlarity    `json:"granularity"`
+	Filter           *Filter       `json:"filter,omitempty"`
+	Intervals        []string      `json:"intervals"`
+	SearchDimensions []string      `json:"searchDimensions,omitempty"`
+	Query            *SearchQuery  `json:"query"`
+	Sort             *SearchSort   `json:"sort"`
+	Context          *QueryContext `json:"context,omitempty"`
+
+	QueryResult  []SearchItem  `json:"-"`
+	ResponseMeta *ResponseMeta `json:"-"`
 }
 
 type SearchItem struct {
@@ -77,10 +94,15 @@ type DimValue struct {
 	Value     string `json:"value"`
 }
 
-func (q *QuerySearch) setup() { q.QueryType = "search" }
+func (q *QuerySearch) setup()                             { q.QueryType = "search" }
+func (q *QuerySearch) getDataSource() string              { return q.DataSource }
+func (q *QuerySearch) getIntervals() []string             { return q.Intervals }
+func (q *QuerySearch) getContext() *QueryContext          { return q.Context }
+func (q *QuerySearch) setContext(ctx *QueryContext)       { q.Context = ctx }
+func (q *QuerySearch) setResponseMeta(meta *ResponseMeta) { q.ResponseMeta = meta }
 func (q *QuerySearch) onResponse(content []byte) error {
 	res := new([]SearchItem)
-	err := json.Unmarshal(content, res)
+	err := unmarshalResponse(content, res)
 	if err != nil {
 		return err
 	}
@@ -93,14 +115,15 @@ func (q *QuerySearch) onResponse(content []byte) error {
 // ---------------------------------
 
 type QuerySegmentMetadata struct {
-	QueryType  string                 `json:"queryType"`
-	DataSource string                 `json:"dataSource"`
-	Intervals  []string               `json:"intervals"`
-	ToInclude  *ToInclude             `json:"toInclude,omitempty"`
-	Merge      interface{}            `json:"merge,omitempty"`
-	Context    map[string]interface{} `json:"context,omitempty"`
-
-	QueryResult []SegmentMetaData `json:"-"`
+	QueryType  string        `json:"queryType"`
+	DataSource string        `json:"dataSource"`
+	Intervals  []string      `json:"intervals"`
+	ToInclude  *ToInclude    `json:"toInclude,omitempty"`
+	Merge      interface{}   `json:"merge,omitempty"`
+	Context    *QueryContext `json:"context,omitempty"`
+
+	QueryResult  []SegmentMetaData `json:"-"`
+	ResponseMeta *ResponseMeta     `json:"-"`
 }
 
 type SegmentMetaData struct {
@@ -115,10 +138,15 @@ type ColumnItem struct {
 	Cardinality interface{} `json:"cardinality"`
 }
 
-func (q *QuerySegmentMetadata) setup() { q.QueryType = "segmentMetadata" }
+func (q *QuerySegmentMetadata) setup()                             { q.QueryType = "segmentMetadata" }
+func (q *QuerySegmentMetadata) getDataSource() string              { return q.DataSource }
+func (q *QuerySegmentMetadata) getIntervals() []string             { return q.Intervals }
+func (q *QuerySegmentMetadata) getContext() *QueryContext          { return q.Context }
+func (q *QuerySegmentMetadata) setContext(ctx *QueryContext)       { q.Context = ctx }
+func (q *QuerySegmentMetadata) setResponseMeta(meta *ResponseMeta) { q.ResponseMeta = meta }
 func (q *QuerySegmentMetadata) onResponse(content []byte) error {
 	res := new([]SegmentMetaData)
-	err := json.Unmarshal(content, res)
+	err := unmarshalResponse(content, res)
 	if err != nil {
 		return err
 	}
@@ -131,12 +159,13 @@ func (q *QuerySegmentMetadata) onResponse(content []byte) error {
 // ---------------------------------
 
 type QueryTimeBoundary struct {
-	QueryType  string                 `json:"queryType"`
-	DataSource string                 `json:"dataSource"`
-	Bound      string                 `json:"bound,omitempty"`
-	Context    map[string]interface{} `json:"context,omitempty"`
+	QueryType  string        `json:"queryType"`
+	DataSource string        `json:"dataSource"`
+	Bound      string        `json:"bound,omitempty"`
+	Context    *QueryContext `json:"context,omitempty"`
 
-	QueryResult []TimeBoundaryItem `json:"-"`
+	QueryResult  []TimeBoundaryItem `json:"-"`
+	ResponseMeta *ResponseMeta      `json:"-"`
 }
 
 type TimeBoundaryItem struct {
@@ -146,13 +175,18 @@ type TimeBoundaryItem struct {
 
 type TimeBoundary struct {
 	MinTime string `json:"minTime"`
-	MaxTime string `json:"minTime"`
+	MaxTime string `json:"maxTime"`
 }
 
-func (q *QueryTimeBoundary) setup() { q.QueryType = "timeBoundary" }
+func (q *QueryTimeBoundary) setup()                             { q.QueryType = "timeBoundary" }
+func (q *QueryTimeBoundary) getDataSource() string              { return q.DataSource }
+func (q *QueryTimeBoundary) getIntervals() []string             { return nil }
+func (q *QueryTimeBoundary) getContext() *QueryContext          { return q.Context }
+func (q *QueryTimeBoundary) setContext(ctx *QueryContext)       { q.Context = ctx }
+func (q *QueryTimeBoundary) setResponseMeta(meta *ResponseMeta) { q.ResponseMeta = meta }
 func (q *QueryTimeBoundary) onResponse(content []byte) error {
 	res := new([]TimeBoundaryItem)
-	err := json.Unmarshal(content, res)
+	err := unmarshalResponse(content, res)
 	if err != nil {
 		return err
 	}
@@ -165,27 +199,33 @@ func (q *QueryTimeBoundary) onResponse(content []byte) error {
 // ---------------------------------
 
 type QueryTimeseries struct {
-	QueryType        string                 `json:"queryType"`
-	DataSource       string                 `json:"dataSource"`
-	Granularity      Granlarity             `json:"granularity"`
-	Filter           *Filter                `json:"filter,omitempty"`
-	Aggregations     []Aggregation          `json:"aggregations"`
-	PostAggregations []PostAggregation      `json:"postAggregations,omitempty"`
-	Intervals        []string               `json:"intervals"`
-	Context          map[string]interface{} `json:"context,omitempty"`
-
-	QueryResult []Timeseries `json:"-"`
+	QueryType        string            `json:"queryType"`
+	DataSource       string            `json:"dataSource"`
+	Granularity      Granlarity        `json:"granularity"`
+	Filter           *Filter           `json:"filter,omitempty"`
+	Aggregations     []Aggregation     `json:"aggregations"`
+	PostAggregations []PostAggregation `json:"postAggregations,omitempty"`
+	Intervals        []string          `json:"intervals"`
+	Context          *QueryContext     `json:"context,omitempty"`
+
+	QueryResult  []Timeseries  `json:"-"`
+	ResponseMeta *ResponseMeta `json:"-"`
 }
 
 type Timeseries struct {
-	Timestamp string                 `json:"timestamp"`
-	Result    map[string]interface{} `json:"result"`
+	Timestamp string `json:"timestamp"`
+	Result    Event  `json:"result"`
 }
 
-func (q *QueryTimeseries) setup() { q.QueryType = "timeseries" }
+func (q *QueryTimeseries) setup()                             { q.QueryType = "timeseries" }
+func (q *QueryTimeseries) getDataSource() string              { return q.DataSource }
+func (q *QueryTimeseries) getIntervals() []string             { return q.Intervals }
+func (q *QueryTimeseries) getContext() *QueryContext          { return q.Context }
+func (q *QueryTimeseries) setContext(ctx *QueryContext)       { q.Context = ctx }
+func (q *QueryTimeseries) setResponseMeta(meta *ResponseMeta) { q.ResponseMeta = meta }
 func (q *QueryTimeseries) onResponse(content []byte) error {
 	res := new([]Timeseries)
-	err := json.Unmarshal(content, res)
+	err := unmarshalResponse(content, res)
 	if err != nil {
 		return err
 	}
@@ -198,30 +238,36 @@ func (q *QueryTimeseries) onResponse(content []byte) error {
 // ---------------------------------
 
 type QueryTopN struct {
-	QueryType        string                 `json:"queryType"`
-	DataSource       string                 `json:"dataSource"`
-	Granularity      Granlarity             `json:"granularity"`
-	Dimension        DimSpec                `json:"dimension"`
-	Threshold        int                    `json:"threshold"`
-	Metric           *TopNMetric            `json:"metric"`
-	Filter           *Filter                `json:"filter,omitempty"`
-	Aggregations     []Aggregation          `json:"aggregations"`
-	PostAggregations []PostAggregation      `json:"postAggregations,omitempty"`
-	Intervals        []string               `json:"intervals"`
-	Context          map[string]interface{} `json:"context,omitempty"`
-
-	QueryResult []TopNItem `json:"-"`
+	QueryType        string            `json:"queryType"`
+	DataSource       string            `json:"dataSource"`
+	Granularity      Granlarity        `json:"granularity"`
+	Dimension        DimSpec           `json:"dimension"`
+	Threshold        int               `json:"threshold"`
+	Metric           *TopNMetric       `json:"metric"`
+	Filter           *Filter           `json:"filter,omitempty"`
+	Aggregations     []Aggregation     `json:"aggregations"`
+	PostAggregations []PostAggregation `json:"postAggregations,omitempty"`
+	Intervals        []string          `json:"intervals"`
+	Context          *QueryContext     `json:"context,omitempty"`
+
+	QueryResult  []TopNItem    `json:"-"`
+	ResponseMeta *ResponseMeta `json:"-"`
 }
 
 type TopNItem struct {
-	Timestamp string                   `json:"timestamp"`
-	Result    []map[string]interface{} `json:"result"`
+	Timestamp string  `json:"timestamp"`
+	Result    []Event `json:"result"`
 }
 
-func (q *QueryTopN) setup() { q.QueryType = "topN" }
+func (q *QueryTopN) setup()                             { q.QueryType = "topN" }
+func (q *QueryTopN) getDataSource() string              { return q.DataSource }
+func (q *QueryTopN) getIntervals() []string             { return q.Intervals }
+func (q *QueryTopN) getContext() *QueryContext          { return q.Context }
+func (q *QueryTopN) setContext(ctx *QueryContext)       { q.Context = ctx }
+func (q *QueryTopN) setResponseMeta(meta *ResponseMeta) { q.ResponseMeta = meta }
 func (q *QueryTopN) onResponse(content []byte) error {
 	res := new([]TopNItem)
-	err := json.Unmarshal(content, res)
+	err := unmarshalResponse(content, res)
 	if err != nil {
 		return err
 	}
@@ -242,9 +288,10 @@ type QuerySelect struct {
 	Metrics     []string               `json:"metrics"`
 	Granularity Granlarity             `json:"granularity"`
 	PagingSpec  map[string]interface{} `json:"pagingSpec,omitempty"`
-	Context     map[string]interface{} `json:"context,omitempty"`
+	Context     *QueryContext          `json:"context,omitempty"`
 
-	QueryResult SelectBlob `json:"-"`
+	QueryResult  SelectBlob    `json:"-"`
+	ResponseMeta *ResponseMeta `json:"-"`
 }
 
 // Select json blob from druid comes back as following:
@@ -252,25 +299,30 @@ type QuerySelect struct {
 // the interesting results are in events blob which we
 // call as 'SelectEvent'.
 type SelectBlob struct {
-        Timestamp string       `json:"timestamp"`
-        Result    SelectResult `json:"result"`
+	Timestamp string       `json:"timestamp"`
+	Result    SelectResult `json:"result"`
 }
 
 type SelectResult struct {
-        PagingIdentifiers map[string]interface{} `json:"pagingIdentifiers"`
-        Events            []SelectEvent          `json:"events"`
+	PagingIdentifiers map[string]interface{} `json:"pagingIdentifiers"`
+	Events            []SelectEvent          `json:"events"`
 }
 
 type SelectEvent struct {
-        SegmentId string                 `json:"segmentId"`
-        Offset    int64                  `json:"offset"`
-        Event     map[string]interface{} `json:"event"`
+	SegmentId string `json:"segmentId"`
+	Offset    int64  `json:"offset"`
+	Event     Event  `json:"event"`
 }
 
-func (q *QuerySelect) setup() { q.QueryType = "select" }
+func (q *QuerySelect) setup()                             { q.QueryType = "select" }
+func (q *QuerySelect) getDataSource() string              { return q.DataSource }
+func (q *QuerySelect) getIntervals() []string             { return q.Intervals }
+func (q *QuerySelect) getContext() *QueryContext          { return q.Context }
+func (q *QuerySelect) setContext(ctx *QueryContext)       { q.Context = ctx }
+func (q *QuerySelect) setResponseMeta(meta *ResponseMeta) { q.ResponseMeta = meta }
 func (q *QuerySelect) onResponse(content []byte) error {
 	res := new([]SelectBlob)
-	err := json.Unmarshal(content, res)
+	err := unmarshalResponse(content, res)
 	if err != nil {
 		return err
 	}
@@ -279,3 +331,85 @@ func (q *QuerySelect) onResponse(content []byte) error {
 	}
 	return nil
 }
+
+// ---------------------------------
+// Scan Query
+// ---------------------------------
+
+type QueryScan struct {
+	QueryType    string        `json:"queryType"`
+	DataSource   string        `json:"dataSource"`
+	Intervals    []string      `json:"intervals"`
+	Filter       *Filter       `json:"filter,omitempty"`
+	Columns      []string      `json:"columns,omitempty"`
+	ResultFormat string        `json:"resultFormat,omitempty"` // "list" (default) or "compactedList".
+	BatchSize    int           `json:"batchSize,omitempty"`
+	Limit        int64         `json:"limit,omitempty"`
+	Offset       int64         `json:"offset,omitempty"`
+	Order        string        `json:"order,omitempty"`
+	Context      *QueryContext `json:"context,omitempty"`
+
+	QueryResult  []ScanBatch   `json:"-"`
+	ResponseMeta *ResponseMeta `json:"-"`
+}
+
+// ScanBatch is a batch of the rows of a segment. The rows of the compactedList result
+// format are decoded into Events keyed by Columns as well.
+type ScanBatch struct {
+	SegmentId string   `json:"segmentId"`
+	Columns   []string `json:"columns"`
+	Events    []Event  `json:"-"`
+}
+
+func (q *QueryScan) setup()                             { q.QueryType = "scan" }
+func (q *QueryScan) getDataSource() string              { return q.DataSource }
+func (q *QueryScan) getIntervals() []string             { return q.Intervals }
+func (q *QueryScan) getContext() *QueryContext          { return q.Context }
+func (q *QueryScan) setContext(ctx *QueryContext)       { q.Context = ctx }
+func (q *QueryScan) setResponseMeta(meta *ResponseMeta) { q.ResponseMeta = meta }
+func (q *QueryScan) onResponse(content []byte) error {
+	var res []struct {
+		ScanBatch
+		Events []interface{} `json:"events"`
+	}
+	err := unmarshalResponse(content, &res)
+	if err != nil {
+		return err
+	}
+	q.QueryResult = make([]ScanBatch, len(res))
+	for i, batch := range res {
+		b := batch.ScanBatch
+		b.Events = make([]Event, len(batch.Events))
+		for j, row := range batch.Events {
+			switch row := row.(type) {
+			case map[string]interface{}:
+				b.Events[j] = row
+			case []interface{}:
+				if len(row) != len(b.Columns) {
+					return fmt.Errorf("godruid: scan row of %d values for %d columns", len(row), len(b.Columns))
+				}
+				e := make(Event, len(row))
+				for k, v := range row {
+					e[b.Columns[k]] = v
+				}
+				b.Events[j] = e
+			default:
+				return fmt.Errorf("godruid: unexpected scan row %T", row)
+			}
+		}
+		q.QueryResult[i] = b
+	}
+	return nil
+}
+
+// unmarshalResponse decodes the response of a query, the numbers in interface{} values
+// are decoded as json.Number to keep the precision of large longs. The Smile responses
+// are decoded directly as well.
+func unmarshalResponse(content []byte, v interface{}) error {
+	if isSmile(content) {
+		return unmarshalSmile(content, v)
+	}
+	d := json.NewDecoder(bytes.NewReader(content))
+	d.UseNumber()
+	return d.Decode(v)
+}
@@ -0,0 +1,119 @@
+package godruid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NewQueryId returns a random UUID as a queryId.
+func NewQueryId() string {
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		panic(fmt.Sprintf("godruid: failed to read random bytes: %v", err))
+	}
+	b[6] = b[6]&0x0F | 0x40 // Version 4.
+	b[8] = b[8]&0x3F | 0x80 // Variant RFC 4122.
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func (c *Client) newQueryId() string {
+	if c.QueryIdFunc != nil {
+		return c.QueryIdFunc()
+	}
+	return NewQueryId()
+}
+
+// CancelQuery asks the broker to cancel the query of queryId.
+func (c *Client) CancelQuery(ctx context.Context, queryId string) error {
+	endPoint := c.EndPoint
+	if endPoint == "" {
+		endPoint = DefaultEndPoint
+	}
+	req, err := c.newRequest(ctx, http.MethodDelete, c.Url+endPoint+"/"+url.PathEscape(queryId), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := readResponse(resp)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return newDruidError(resp, body)
+	}
+	return nil
+}
+
+// RunningQuery is a query sent to the broker and not answered yet.
+type RunningQuery struct {
+	QueryId    string
+	QueryType  string
+	DataSource string
+	Start      time.Time
+}
+
+// QueryRegistry keeps the queries running, e.g. to show and cancel them in an admin page.
+// The zero value is ready to use.
+type QueryRegistry struct {
+	mu      sync.Mutex
+	running map[*RunningQuery]struct{}
+}
+
+func NewQueryRegistry() *QueryRegistry {
+	return &QueryRegistry{}
+}
+
+// List returns the queries running, in the order they started.
+func (r *QueryRegistry) List() []RunningQuery {
+	r.mu.Lock()
+	res := make([]RunningQuery, 0, len(r.running))
+	for q := range r.running {
+		res = append(res, *q)
+	}
+	r.mu.Unlock()
+	sort.Slice(res, func(a, b int) bool { return res[a].Start.Before(res[b].Start) })
+	return res
+}
+
+// add adds the query of req, and returns the function removing it.
+func (r *QueryRegistry) add(req []byte) func() {
+	var summary querySummary
+	json.Unmarshal(req, &summary)
+	q := &RunningQuery{
+		QueryId:    queryIdOf(req),
+		QueryType:  summary.QueryType,
+		DataSource: unquoteJSON(summary.DataSource),
+		Start:      time.Now(),
+	}
+	r.mu.Lock()
+	if r.running == nil {
+		r.running = map[*RunningQuery]struct{}{}
+	}
+	r.running[q] = struct{}{}
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		delete(r.running, q)
+		r.mu.Unlock()
+	}
+}
+
+// RunningQueries returns the queries running of Registry, nil if there is no Registry.
+func (c *Client) RunningQueries() []RunningQuery {
+	if c.Registry == nil {
+		return nil
+	}
+	return c.Registry.List()
+}
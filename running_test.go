@@ -0,0 +1,68 @@
+package godruid
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunningQueries(t *testing.T) {
+	Convey("TestRunningQueries", t, func() {
+		cancel := make(chan struct{})
+		var canceledId string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodDelete {
+				canceledId = strings.TrimPrefix(r.URL.Path, DefaultEndPoint+"/")
+				close(cancel)
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			select {
+			case <-cancel:
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"error":"Query cancelled"}`))
+			case <-time.After(5 * time.Second):
+				w.Write([]byte(`[]`))
+			}
+		}))
+		defer server.Close()
+
+		client := Client{Url: server.URL, Registry: NewQueryRegistry()}
+		query := &QueryTimeseries{
+			DataSource:   "events",
+			Intervals:    []string{"2015-01-01/2015-01-02"},
+			Granularity:  GranAll,
+			Aggregations: []Aggregation{AggCount("count")},
+		}
+		done := make(chan error)
+		go func() { done <- client.Query(query) }()
+
+		var running []RunningQuery
+		for len(running) == 0 {
+			time.Sleep(time.Millisecond)
+			running = client.RunningQueries()
+		}
+		So(running[0].QueryType, ShouldEqual, "timeseries")
+		So(running[0].DataSource, ShouldEqual, "events")
+		So(running[0].QueryId, ShouldNotEqual, "")
+		So(query.Context, ShouldBeNil)
+
+		So(client.CancelQuery(context.Background(), running[0].QueryId), ShouldEqual, nil)
+		So(canceledId, ShouldEqual, running[0].QueryId)
+		So(ErrorClass(<-done), ShouldEqual, "Query cancelled")
+		So(len(client.RunningQueries()), ShouldEqual, 0)
+	})
+}
+
+func TestNewQueryId(t *testing.T) {
+	Convey("TestNewQueryId", t, func() {
+		id := NewQueryId()
+		So(regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id), ShouldBeTrue)
+		So(NewQueryId(), ShouldNotEqual, id)
+	})
+}
@@ -0,0 +1,654 @@
+package godruid
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SmileContentType is the content type of the Smile binary JSON format of Jackson.
+const SmileContentType = "application/x-jackson-smile"
+
+// The max entries of the shared name and value tables of Smile.
+const smileMaxShared = 1024
+
+var errInvalidSmile = errors.New("godruid: invalid Smile data")
+
+// isSmile reports whether data is a Smile document, which starts with ":)\n", and a
+// JSON document never does.
+func isSmile(data []byte) bool {
+	return len(data) >= 4 && data[0] == ':' && data[1] == ')' && data[2] == '\n'
+}
+
+// unmarshalSmile decodes the Smile document into v directly, the same as unmarshalResponse
+// decodes JSON: the struct fields are matched by their json tags, and the numbers in
+// interface{} values are json.Number.
+func unmarshalSmile(data []byte, v interface{}) error {
+	if !isSmile(data) {
+		return fmt.Errorf("%w: no header", errInvalidSmile)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("godruid: can't decode Smile into %T", v)
+	}
+	d := &smileDecoder{
+		data:         data,
+		pos:          4,
+		sharedNames:  data[3]&0x01 != 0,
+		sharedValues: data[3]&0x02 != 0,
+	}
+	if err := d.value(rv.Elem()); err != nil {
+		return err
+	}
+	if d.pos < len(d.data) && d.data[d.pos] != 0xFF {
+		return fmt.Errorf("%w: unexpected byte 0x%02X at %d", errInvalidSmile, d.data[d.pos], d.pos)
+	}
+	return nil
+}
+
+// smileText returns the Smile document as JSON, for the logs and error messages.
+func smileText(data []byte) []byte {
+	var v interface{}
+	if err := unmarshalSmile(data, &v); err != nil {
+		return []byte(err.Error())
+	}
+	text, err := json.Marshal(v)
+	if err != nil {
+		return []byte(err.Error())
+	}
+	return text
+}
+
+type smileDecoder struct {
+	data         []byte
+	pos          int
+	sharedNames  bool
+	sharedValues bool
+	names        []string
+	values       []string
+}
+
+func (d *smileDecoder) next() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, fmt.Errorf("%w: unexpected end", errInvalidSmile)
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *smileDecoder) take(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, fmt.Errorf("%w: unexpected end", errInvalidSmile)
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// untilEnd returns the bytes before the end of string marker.
+func (d *smileDecoder) untilEnd() ([]byte, error) {
+	i := bytes.IndexByte(d.data[d.pos:], 0xFC)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: unterminated string", errInvalidSmile)
+	}
+	b := d.data[d.pos : d.pos+i]
+	d.pos += i + 1
+	return b, nil
+}
+
+// vint reads an unsigned variable length integer, the last byte has the high bit set
+// and 6 bits of data.
+func (d *smileDecoder) vint() (uint64, error) {
+	var v uint64
+	for i := 0; i < 10; i++ {
+		b, err := d.next()
+		if err != nil {
+			return 0, err
+		}
+		if b&0x80 != 0 {
+			return v<<6 | uint64(b&0x3F), nil
+		}
+		v = v<<7 | uint64(b)
+	}
+	return 0, fmt.Errorf("%w: invalid vint", errInvalidSmile)
+}
+
+func zigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// bits7 reads n bytes of 7 bits each as an integer.
+func (d *smileDecoder) bits7(n int) (uint64, error) {
+	b, err := d.take(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, x := range b {
+		v = v<<7 | uint64(x&0x7F)
+	}
+	return v, nil
+}
+
+// binary7 reads the 7-bit encoded binary data of n bytes.
+func (d *smileDecoder) binary7(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)*8/7 {
+		return nil, fmt.Errorf("%w: invalid binary length %d", errInvalidSmile, n)
+	}
+	res := make([]byte, 0, n)
+	for n-len(res) >= 7 {
+		v, err := d.bits7(8)
+		if err != nil {
+			return nil, err
+		}
+		for shift := 48; shift >= 0; shift -= 8 {
+			res = append(res, byte(v>>uint(shift)))
+		}
+	}
+	if left := n - len(res); left > 0 {
+		// The remaining bits of the last byte are right aligned.
+		b, err := d.take(left + 1)
+		if err != nil {
+			return nil, err
+		}
+		v := uint64(b[0])
+		for i := 1; i < left; i++ {
+			v = v<<7 | uint64(b[i])
+			res = append(res, byte(v>>uint(7*i)))
+		}
+		v <<= uint(left)
+		res = append(res, byte(v+uint64(b[left])))
+	}
+	return res, nil
+}
+
+func (d *smileDecoder) length() (int, error) {
+	n, err := d.vint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.data))*2 {
+		return 0, fmt.Errorf("%w: invalid length %d", errInvalidSmile, n)
+	}
+	return int(n), nil
+}
+
+// addShared adds s to the shared table, which is cleared when it's full.
+func addShared(table []string, s string) []string {
+	if len(table) >= smileMaxShared {
+		table = table[:0]
+	}
+	return append(table, s)
+}
+
+func (d *smileDecoder) sharedValue(i int) (string, error) {
+	if i >= len(d.values) {
+		return "", fmt.Errorf("%w: invalid shared value reference %d", errInvalidSmile, i)
+	}
+	return d.values[i], nil
+}
+
+// value decodes the next value into v.
+func (d *smileDecoder) value(v reflect.Value) error {
+	b, err := d.next()
+	if err != nil {
+		return err
+	}
+	switch b {
+	case 0xF8:
+		return d.array(v)
+	case 0xFA:
+		return d.object(v)
+	}
+	x, err := d.scalar(b)
+	if err != nil {
+		return err
+	}
+	return d.set(v, x)
+}
+
+// scalar reads the scalar value starting with b, as nil, bool, string or json.Number.
+func (d *smileDecoder) scalar(b byte) (interface{}, error) {
+	switch {
+	case b >= 0x01 && b <= 0x1F:
+		return d.sharedValue(int(b) - 1)
+	case b == 0x20:
+		return "", nil
+	case b == 0x21:
+		return nil, nil
+	case b == 0x22:
+		return false, nil
+	case b == 0x23:
+		return true, nil
+	case b == 0x24 || b == 0x25:
+		v, err := d.vint()
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(strconv.FormatInt(zigzag(v), 10)), nil
+	case b == 0x26:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		data, err := d.binary7(n)
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(bigIntOf(data).String()), nil
+	case b == 0x28:
+		v, err := d.bits7(5)
+		if err != nil {
+			return nil, err
+		}
+		return floatNumber(float64(math.Float32frombits(uint32(v))), 32), nil
+	case b == 0x29:
+		v, err := d.bits7(10)
+		if err != nil {
+			return nil, err
+		}
+		return floatNumber(math.Float64frombits(v), 64), nil
+	case b == 0x2A:
+		scale, err := d.vint()
+		if err != nil {
+			return nil, err
+		}
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		data, err := d.binary7(n)
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(decimalString(bigIntOf(data), zigzag(scale))), nil
+	case b >= 0x40 && b <= 0xBF:
+		// The tiny and short ASCII and Unicode strings.
+		n := int(b&0x1F) + 1
+		switch b & 0xE0 {
+		case 0x60:
+			n += 32
+		case 0x80:
+			n++
+		case 0xA0:
+			n += 33
+		}
+		data, err := d.take(n)
+		if err != nil {
+			return nil, err
+		}
+		s := string(data)
+		if d.sharedValues {
+			d.values = addShared(d.values, s)
+		}
+		return s, nil
+	case b >= 0xC0 && b <= 0xDF:
+		return json.Number(strconv.FormatInt(zigzag(uint64(b&0x1F)), 10)), nil
+	case b == 0xE0 || b == 0xE4:
+		data, err := d.untilEnd()
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	case b == 0xE8 || b == 0xFD:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		var data []byte
+		if b == 0xE8 {
+			data, err = d.binary7(n)
+		} else {
+			data, err = d.take(n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(data), nil
+	case b >= 0xEC && b <= 0xEF:
+		low, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		return d.sharedValue(int(b&0x03)<<8 | int(low))
+	}
+	return nil, fmt.Errorf("%w: unexpected byte 0x%02X at %d", errInvalidSmile, b, d.pos-1)
+}
+
+// floatNumber returns f as rendered by Druid in JSON, the NaN and infinite values are
+// nil as JSON can't have them.
+func floatNumber(f float64, bitSize int) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return json.Number(formatDouble(f, bitSize))
+}
+
+func (d *smileDecoder) array(v reflect.Value) error {
+	v = allocPtr(v)
+	switch {
+	case isEmptyInterface(v):
+		arr := []interface{}{}
+		for !d.end(0xF9) {
+			var x interface{}
+			if err := d.value(reflect.ValueOf(&x).Elem()); err != nil {
+				return err
+			}
+			arr = append(arr, x)
+		}
+		v.Set(reflect.ValueOf(arr))
+		return nil
+	case v.Kind() == reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), 0, 0)
+		zero := reflect.Zero(v.Type().Elem())
+		for i := 0; !d.end(0xF9); i++ {
+			s = reflect.Append(s, zero)
+			if err := d.value(s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return d.typeError("array", v)
+}
+
+func (d *smileDecoder) object(v reflect.Value) error {
+	v = allocPtr(v)
+	var set func(name string) error
+	switch {
+	case isEmptyInterface(v):
+		m := map[string]interface{}{}
+		v.Set(reflect.ValueOf(m))
+		set = func(name string) error {
+			var x interface{}
+			if err := d.value(reflect.ValueOf(&x).Elem()); err != nil {
+				return err
+			}
+			m[name] = x
+			return nil
+		}
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		set = func(name string) error {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := d.value(elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(name).Convert(v.Type().Key()), elem)
+			return nil
+		}
+	case v.Kind() == reflect.Struct:
+		fields := jsonFieldsOf(v.Type())
+		set = func(name string) error {
+			index, ok := fields.byName[name]
+			if !ok {
+				index, ok = fields.byFold[strings.ToLower(name)]
+			}
+			if !ok {
+				var skipped interface{}
+				return d.value(reflect.ValueOf(&skipped).Elem())
+			}
+			return d.value(fieldByIndex(v, index))
+		}
+	default:
+		return d.typeError("object", v)
+	}
+	for {
+		b, err := d.next()
+		if err != nil {
+			return err
+		}
+		if b == 0xFB {
+			return nil
+		}
+		name, err := d.name(b)
+		if err != nil {
+			return err
+		}
+		if err := set(name); err != nil {
+			return err
+		}
+	}
+}
+
+// end consumes the end marker if it's next.
+func (d *smileDecoder) end(marker byte) bool {
+	if d.pos < len(d.data) && d.data[d.pos] == marker {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// set sets the scalar x to v, like encoding/json does.
+func (d *smileDecoder) set(v reflect.Value, x interface{}) error {
+	if x == nil {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+	v = allocPtr(v)
+	if isEmptyInterface(v) {
+		v.Set(reflect.ValueOf(x))
+		return nil
+	}
+	switch x := x.(type) {
+	case bool:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(x)
+			return nil
+		}
+		return d.typeError("bool", v)
+	case string:
+		if v.Kind() == reflect.String && v.Type() != numberType {
+			v.SetString(x)
+			return nil
+		}
+		return d.typeError("string", v)
+	case json.Number:
+		switch v.Kind() {
+		case reflect.String:
+			if v.Type() == numberType {
+				v.SetString(string(x))
+				return nil
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(string(x), 10, 64)
+			if err == nil && !v.OverflowInt(n) {
+				v.SetInt(n)
+				return nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseUint(string(x), 10, 64)
+			if err == nil && !v.OverflowUint(n) {
+				v.SetUint(n)
+				return nil
+			}
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(string(x), v.Type().Bits())
+			if err == nil {
+				v.SetFloat(f)
+				return nil
+			}
+		}
+		return d.typeError("number "+string(x), v)
+	}
+	return d.typeError(fmt.Sprintf("%T", x), v)
+}
+
+// typeError returns the same error as encoding/json, so ErrorClass tells it's a decode error.
+func (d *smileDecoder) typeError(value string, v reflect.Value) error {
+	return &json.UnmarshalTypeError{Value: value, Type: v.Type(), Offset: int64(d.pos)}
+}
+
+// allocPtr allocates the nil pointers and returns the value pointed to.
+func allocPtr(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func isEmptyInterface(v reflect.Value) bool {
+	return v.Kind() == reflect.Interface && v.NumMethod() == 0
+}
+
+// jsonFields are the fields of a struct type by their JSON names.
+type jsonFields struct {
+	byName map[string][]int
+	byFold map[string][]int // By the lower case names, as encoding/json matches case-insensitively.
+}
+
+var jsonFieldsCache sync.Map // reflect.Type -> *jsonFields
+
+func jsonFieldsOf(t reflect.Type) *jsonFields {
+	if fields, ok := jsonFieldsCache.Load(t); ok {
+		return fields.(*jsonFields)
+	}
+	fields := &jsonFields{byName: map[string][]int{}, byFold: map[string][]int{}}
+	fields.add(t, nil)
+	jsonFieldsCache.Store(t, fields)
+	return fields
+}
+
+// add adds the fields of t, the fields of the embedded structs are added after the
+// others, so the outer ones win.
+func (fields *jsonFields) add(t reflect.Type, index []int) {
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, f)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		if _, ok := fields.byName[name]; !ok {
+			fields.byName[name] = fieldIndex
+		}
+		if _, ok := fields.byFold[strings.ToLower(name)]; !ok {
+			fields.byFold[strings.ToLower(name)] = fieldIndex
+		}
+	}
+	for _, f := range embedded {
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		fields.add(ft, append(append([]int{}, index...), f.Index...))
+	}
+}
+
+// fieldByIndex returns the field of v, allocating the nil embedded pointers on the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			v = allocPtr(v)
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// name reads the field name starting with b.
+func (d *smileDecoder) name(b byte) (string, error) {
+	var ref int
+	switch {
+	case b == 0x20:
+		return "", nil
+	case b >= 0x30 && b <= 0x33:
+		low, err := d.next()
+		if err != nil {
+			return "", err
+		}
+		ref = int(b&0x03)<<8 | int(low)
+	case b >= 0x40 && b <= 0x7F:
+		ref = int(b & 0x3F)
+	case b == 0x34 || b >= 0x80 && b <= 0xF7:
+		var data []byte
+		var err error
+		switch {
+		case b == 0x34:
+			data, err = d.untilEnd()
+		case b <= 0xBF:
+			data, err = d.take(int(b&0x3F) + 1)
+		default:
+			data, err = d.take(int(b&0x3F) + 2)
+		}
+		if err != nil {
+			return "", err
+		}
+		name := string(data)
+		if d.sharedNames {
+			d.names = addShared(d.names, name)
+		}
+		return name, nil
+	default:
+		return "", fmt.Errorf("%w: unexpected name byte 0x%02X at %d", errInvalidSmile, b, d.pos-1)
+	}
+	if ref >= len(d.names) {
+		return "", fmt.Errorf("%w: invalid shared name reference %d", errInvalidSmile, ref)
+	}
+	return d.names[ref], nil
+}
+
+// bigIntOf returns the big-endian two's complement integer.
+func bigIntOf(data []byte) *big.Int {
+	v := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(data)*8)))
+	}
+	return v
+}
+
+// decimalString returns unscaled * 10^-scale as a JSON number.
+func decimalString(unscaled *big.Int, scale int64) string {
+	s := new(big.Int).Abs(unscaled).String()
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	switch {
+	case scale <= 0:
+		if unscaled.Sign() == 0 {
+			return "0"
+		}
+		return sign + s + "e" + strconv.FormatInt(-scale, 10)
+	case scale > int64(len(s))+20:
+		return sign + s + "e" + strconv.FormatInt(-scale, 10)
+	case int64(len(s)) > scale:
+		return sign + s[:int64(len(s))-scale] + "." + s[int64(len(s))-scale:]
+	}
+	return sign + "0." + strings.Repeat("0", int(scale)-len(s)) + s
+}
@@ -1,5 +1,12 @@
 package godruid
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
 // Defines some small spec like structs here.
 
 // ---------------------------------
@@ -35,6 +42,60 @@ func LimitDefault(limit int, columns ...[]Column) *Limit {
 	}
 }
 
+// apply sorts and limits the groupBy rows on client side, the same as Druid does.
+func (l *Limit) apply(items []GroupbyItem) []GroupbyItem {
+	if l == nil {
+		return items
+	}
+	if len(l.Columns) > 0 {
+		sort.SliceStable(items, func(a, b int) bool {
+			for _, c := range l.Columns {
+				cmp := compareValues(items[a].Event[c.Dimension], items[b].Event[c.Dimension], c.AsNumber)
+				if cmp == 0 {
+					continue
+				}
+				if strings.EqualFold(c.Direction, DirectionDESC) {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+	if l.Limit > 0 && len(items) > l.Limit {
+		items = items[:l.Limit]
+	}
+	return items
+}
+
+// compareValues compares two result values, the numbers are compared numerically,
+// and so are the strings if asNumber. The nil values go first.
+func compareValues(x, y interface{}, asNumber bool) int {
+	if x == nil || y == nil {
+		switch {
+		case x == y:
+			return 0
+		case x == nil:
+			return -1
+		}
+		return 1
+	}
+	_, xNum := toFloat64(x)
+	_, yNum := toFloat64(y)
+	if xNum && yNum {
+		return compareNumbers(x, y)
+	}
+	xs, ys := fmt.Sprint(x), fmt.Sprint(y)
+	if asNumber {
+		xf, xErr := strconv.ParseFloat(xs, 64)
+		yf, yErr := strconv.ParseFloat(ys, 64)
+		if xErr == nil && yErr == nil {
+			return compareNumbers(xf, yf)
+		}
+	}
+	return strings.Compare(xs, ys)
+}
+
 // ---------------------------------
 // SearchQuerySpec
 // ---------------------------------
@@ -118,6 +179,60 @@ func TopNMetricInverted(metric *TopNMetric) *TopNMetric {
 	}
 }
 
+// less reports whether the topN result entry a goes before b, dimension is the output name of the topN dimension.
+func (m *TopNMetric) less(a, b map[string]interface{}, dimension string) bool {
+	if m == nil {
+		return false
+	}
+	switch m.Type {
+	case "numeric":
+		metric, _ := m.Metric.(string)
+		return compareNumbers(a[metric], b[metric]) > 0
+	case "inverted":
+		inner, _ := m.Metric.(*TopNMetric)
+		return inner.less(b, a, dimension)
+	case "alphaNumeric":
+		return compareAlphaNumeric(fmt.Sprint(a[dimension]), fmt.Sprint(b[dimension])) < 0
+	}
+	return fmt.Sprint(a[dimension]) < fmt.Sprint(b[dimension])
+}
+
+// compareAlphaNumeric compares the strings with the digit runs compared numerically, e.g. "a2" < "a10".
+func compareAlphaNumeric(x, y string) int {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	for len(x) > 0 && len(y) > 0 {
+		if isDigit(x[0]) && isDigit(y[0]) {
+			i, j := 0, 0
+			for i < len(x) && isDigit(x[i]) {
+				i++
+			}
+			for j < len(y) && isDigit(y[j]) {
+				j++
+			}
+			xn, yn := strings.TrimLeft(x[:i], "0"), strings.TrimLeft(y[:j], "0")
+			if len(xn) != len(yn) {
+				if len(xn) < len(yn) {
+					return -1
+				}
+				return 1
+			}
+			if cmp := strings.Compare(xn, yn); cmp != 0 {
+				return cmp
+			}
+			x, y = x[i:], y[j:]
+			continue
+		}
+		if x[0] != y[0] {
+			if x[0] < y[0] {
+				return -1
+			}
+			return 1
+		}
+		x, y = x[1:], y[1:]
+	}
+	return len(x) - len(y)
+}
+
 // ---------------------------------
 // SearchSortSpec
 // ---------------------------------
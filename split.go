@@ -0,0 +1,395 @@
+package godruid
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultSplitWorkers is the max concurrent queries of QuerySplit if SplitSpec.Workers is not set.
+const DefaultSplitWorkers = 4
+
+// SplitSpec tells QuerySplit how to split the intervals of a query.
+// Either Period or Pieces should be set.
+type SplitSpec struct {
+	Period  Period // Split every period, e.g. MustParsePeriod("P1W").
+	Pieces  int    // Split the span of the intervals into Pieces chunks.
+	Workers int    // The max concurrent queries.
+}
+
+// QuerySplit splits the intervals of query into chunks, queries the chunks concurrently,
+// and merges the results into query as if it was sent as a whole.
+//
+// Timeseries, groupBy and topN queries are supported. The rows of the same time bucket
+// are re-aggregated with the aggregations of query, so only the aggregations which could
+// be combined (count, sums, mins and maxes) and the post aggregations which could be
+// computed on client side (arithmetic, fieldAccess and constant) are allowed. The
+// LimitSpec and Having of groupBy queries are applied after merging.
+//
+// TopN results are approximate like they are in Druid: a dimension value outside the
+// top list of a chunk doesn't contribute to the merged result of that chunk.
+//
+// ctx could cancel the queries, and the queries still running are canceled once one of
+// them fails.
+func (c *Client) QuerySplit(ctx context.Context, query Query, spec SplitSpec) error {
+	switch q := query.(type) {
+	case *QueryTimeseries:
+		if err := checkMergeable(q.Aggregations, q.PostAggregations, nil); err != nil {
+			return err
+		}
+		keyOf, err := bucketKeyer(q.Granularity)
+		if err != nil {
+			return err
+		}
+		chunks, err := spec.chunks(q.Intervals)
+		if err != nil {
+			return err
+		}
+		parts := make([]*QueryTimeseries, len(chunks))
+		queries := make([]Query, len(chunks))
+		for i, chunk := range chunks {
+			part := *q
+			part.Intervals = chunk.Strings()
+			part.QueryResult = nil
+			parts[i] = &part
+			queries[i] = &part
+		}
+		if err := c.queryAll(ctx, queries, spec.Workers); err != nil {
+			return err
+		}
+		return q.merge(parts, keyOf)
+	case *QueryGroupBy:
+		if err := checkMergeable(q.Aggregations, q.PostAggregations, q.Having); err != nil {
+			return err
+		}
+		keyOf, err := bucketKeyer(q.Granularity)
+		if err != nil {
+			return err
+		}
+		chunks, err := spec.chunks(q.Intervals)
+		if err != nil {
+			return err
+		}
+		parts := make([]*QueryGroupBy, len(chunks))
+		queries := make([]Query, len(chunks))
+		for i, chunk := range chunks {
+			part := *q
+			part.Intervals = chunk.Strings()
+			part.LimitSpec = nil
+			part.Having = nil
+			part.QueryResult = nil
+			parts[i] = &part
+			queries[i] = &part
+		}
+		if err := c.queryAll(ctx, queries, spec.Workers); err != nil {
+			return err
+		}
+		return q.merge(parts, keyOf)
+	case *QueryTopN:
+		if err := checkMergeable(q.Aggregations, q.PostAggregations, nil); err != nil {
+			return err
+		}
+		keyOf, err := bucketKeyer(q.Granularity)
+		if err != nil {
+			return err
+		}
+		chunks, err := spec.chunks(q.Intervals)
+		if err != nil {
+			return err
+		}
+		parts := make([]*QueryTopN, len(chunks))
+		queries := make([]Query, len(chunks))
+		for i, chunk := range chunks {
+			part := *q
+			part.Intervals = chunk.Strings()
+			// Ask for more entries from each chunk to make the merged result more accurate,
+			// the same as the minTopNThreshold of Druid.
+			if part.Threshold < defaultMinTopNThreshold {
+				part.Threshold = defaultMinTopNThreshold
+			}
+			part.QueryResult = nil
+			parts[i] = &part
+			queries[i] = &part
+		}
+		if err := c.queryAll(ctx, queries, spec.Workers); err != nil {
+			return err
+		}
+		return q.merge(parts, keyOf)
+	}
+	return fmt.Errorf("godruid: QuerySplit doesn't support %T", query)
+}
+
+const defaultMinTopNThreshold = 1000
+
+func (s SplitSpec) chunks(ss []string) (res []Intervals, err error) {
+	intervals, err := ParseIntervals(ss)
+	if err != nil {
+		return nil, err
+	}
+	intervals = intervals.Normalize()
+	switch {
+	case !s.Period.IsZero():
+		for _, i := range intervals {
+			for _, chunk := range i.SplitBy(s.Period) {
+				res = append(res, Intervals{chunk})
+			}
+		}
+	case s.Pieces > 1:
+		for _, piece := range intervals.Span().Split(s.Pieces) {
+			if chunk := intervals.Intersect(piece); len(chunk) > 0 {
+				res = append(res, chunk)
+			}
+		}
+	default:
+		res = append(res, intervals)
+	}
+	return
+}
+
+// queryAll sends the queries with at most workers queries at the same time,
+// and returns the first error. The other queries are canceled on the first error.
+func (c *Client) queryAll(ctx context.Context, queries []Query, workers int) error {
+	if workers <= 0 {
+		workers = DefaultSplitWorkers
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		sem      = make(chan struct{}, workers)
+	)
+	for _, q := range queries {
+		sem <- struct{}{}
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(q Query) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := c.QueryWithContext(ctx, q); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}(q)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func checkMergeable(aggs []Aggregation, postAggs []PostAggregation, having *Having) error {
+	for _, agg := range aggs {
+		if !agg.combinable() {
+			return fmt.Errorf("godruid: aggregation %q of type %q can't be merged", agg.Name, agg.Type)
+		}
+	}
+	for _, pa := range postAggs {
+		if !pa.computable() {
+			return fmt.Errorf("godruid: post aggregation %q of type %q can't be merged", pa.Name, pa.Type)
+		}
+	}
+	if !having.matchable() {
+		return fmt.Errorf("godruid: having of type %q can't be merged", having.Type)
+	}
+	return nil
+}
+
+// combineRow aggregates the values of src into dst, the numbers stay json.Number.
+func combineRow(dst, src map[string]interface{}, aggs []Aggregation) {
+	for _, agg := range aggs {
+		dst[agg.Name] = toNumber(agg.combine(dst[agg.Name], src[agg.Name]))
+	}
+}
+
+func computePostAggs(row map[string]interface{}, postAggs []PostAggregation) error {
+	for _, pa := range postAggs {
+		v, err := pa.compute(row)
+		if err != nil {
+			return err
+		}
+		row[pa.Name] = toNumber(v)
+	}
+	return nil
+}
+
+func copyRow(row map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		res[k] = v
+	}
+	return res
+}
+
+// bucketKeyer returns the function which returns the key of the time bucket of gran the
+// result with timestamp is in. A bucket cut by the chunks comes back from every chunk with
+// the start of the chunk as its timestamp, so the timestamps are truncated to the buckets.
+func bucketKeyer(gran Granlarity) (func(timestamp string) (string, error), error) {
+	b, err := BucketerOf(gran)
+	if err != nil {
+		return nil, err
+	}
+	return func(timestamp string) (string, error) {
+		if b.IsAll() {
+			return "", nil
+		}
+		t, err := parseISOTime(timestamp)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(b.Truncate(t).UnixNano(), 10), nil
+	}, nil
+}
+
+func (q *QueryTimeseries) merge(parts []*QueryTimeseries, keyOf func(timestamp string) (string, error)) error {
+	var res []Timeseries
+	index := map[string]int{}
+	for _, part := range parts {
+		for _, item := range part.QueryResult {
+			key, err := keyOf(item.Timestamp)
+			if err != nil {
+				return err
+			}
+			if i, ok := index[key]; ok {
+				combineRow(res[i].Result, item.Result, q.Aggregations)
+				if timestampBefore(item.Timestamp, res[i].Timestamp) {
+					res[i].Timestamp = item.Timestamp
+				}
+				continue
+			}
+			index[key] = len(res)
+			res = append(res, Timeseries{Timestamp: item.Timestamp, Result: copyRow(item.Result)})
+		}
+	}
+	for _, item := range res {
+		if err := computePostAggs(item.Result, q.PostAggregations); err != nil {
+			return err
+		}
+	}
+	sort.SliceStable(res, func(a, b int) bool { return timestampBefore(res[a].Timestamp, res[b].Timestamp) })
+	q.QueryResult = res
+	return nil
+}
+
+func (q *QueryGroupBy) merge(parts []*QueryGroupBy, keyOf func(timestamp string) (string, error)) error {
+	dims := make([]string, len(q.Dimensions))
+	for i, dim := range q.Dimensions {
+		dims[i] = dimOutputName(dim)
+	}
+
+	var res []GroupbyItem
+	var buckets []string
+	index := map[string]int{}
+	// The rows of a bucket are stamped with the earliest timestamp of the bucket, even
+	// if their dimensions are only in the later chunks.
+	stamps := map[string]string{}
+	for _, part := range parts {
+		for _, item := range part.QueryResult {
+			bucket, err := keyOf(item.Timestamp)
+			if err != nil {
+				return err
+			}
+			if stamp, ok := stamps[bucket]; !ok || timestampBefore(item.Timestamp, stamp) {
+				stamps[bucket] = item.Timestamp
+			}
+			key := bucket + "\x01" + dimsKey(item.Event, q.Dimensions)
+			if i, ok := index[key]; ok {
+				combineRow(res[i].Event, item.Event, q.Aggregations)
+				continue
+			}
+			index[key] = len(res)
+			res = append(res, GroupbyItem{Version: item.Version, Timestamp: item.Timestamp, Event: copyRow(item.Event)})
+			buckets = append(buckets, bucket)
+		}
+	}
+
+	kept := res[:0]
+	for i, item := range res {
+		item.Timestamp = stamps[buckets[i]]
+		if err := computePostAggs(item.Event, q.PostAggregations); err != nil {
+			return err
+		}
+		if q.Having.match(item.Event) {
+			kept = append(kept, item)
+		}
+	}
+	res = kept
+
+	// Druid sorts the rows by time and then the dimensions.
+	sort.SliceStable(res, func(a, b int) bool {
+		if res[a].Timestamp != res[b].Timestamp {
+			return timestampBefore(res[a].Timestamp, res[b].Timestamp)
+		}
+		for _, dim := range dims {
+			if cmp := compareValues(res[a].Event[dim], res[b].Event[dim], false); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+	q.QueryResult = q.LimitSpec.apply(res)
+	return nil
+}
+
+func (q *QueryTopN) merge(parts []*QueryTopN, keyOf func(timestamp string) (string, error)) error {
+	dim := dimOutputName(q.Dimension)
+
+	var res []TopNItem
+	index := map[string]int{}
+	entryIndex := []map[string]int{}
+	for _, part := range parts {
+		for _, item := range part.QueryResult {
+			key, err := keyOf(item.Timestamp)
+			if err != nil {
+				return err
+			}
+			i, ok := index[key]
+			if !ok {
+				i = len(res)
+				index[key] = i
+				res = append(res, TopNItem{Timestamp: item.Timestamp})
+				entryIndex = append(entryIndex, map[string]int{})
+			} else if timestampBefore(item.Timestamp, res[i].Timestamp) {
+				res[i].Timestamp = item.Timestamp
+			}
+			for _, entry := range item.Result {
+				value := fmt.Sprint(entry[dim])
+				if j, ok := entryIndex[i][value]; ok {
+					combineRow(res[i].Result[j], entry, q.Aggregations)
+					continue
+				}
+				entryIndex[i][value] = len(res[i].Result)
+				res[i].Result = append(res[i].Result, copyRow(entry))
+			}
+		}
+	}
+
+	for i := range res {
+		entries := res[i].Result
+		for _, entry := range entries {
+			if err := computePostAggs(entry, q.PostAggregations); err != nil {
+				return err
+			}
+		}
+		sort.SliceStable(entries, func(a, b int) bool { return q.Metric.less(entries[a], entries[b], dim) })
+		if q.Threshold > 0 && len(entries) > q.Threshold {
+			res[i].Result = entries[:q.Threshold]
+		}
+	}
+	sort.SliceStable(res, func(a, b int) bool { return timestampBefore(res[a].Timestamp, res[b].Timestamp) })
+	q.QueryResult = res
+	return nil
+}
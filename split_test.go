@@ -0,0 +1,170 @@
+package godruid
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBroker answers the queries with the responses keyed by the start of their first interval.
+func fakeBroker(responses map[string]string) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var req struct {
+			Intervals Intervals `json:"intervals"`
+		}
+		json.Unmarshal(body, &req)
+		key := req.Intervals[0].Start.UTC().Format("2006-01-02")
+		mu.Lock()
+		got = append(got, key)
+		mu.Unlock()
+		w.Write([]byte(responses[key]))
+	}))
+	return server, &got
+}
+
+func TestQuerySplit(t *testing.T) {
+	Convey("TestQuerySplit", t, func() {
+		interval := Between(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2015, 1, 3, 0, 0, 0, 0, time.UTC))
+
+		Convey("groupBy", func() {
+			server, got := fakeBroker(map[string]string{
+				"2015-01-01": `[
+					{"version":"v1","timestamp":"2015-01-01T00:00:00.000Z","event":{"country":"cn","count":2,"bytes":10}},
+					{"version":"v1","timestamp":"2015-01-01T00:00:00.000Z","event":{"country":"us","count":1,"bytes":40}}]`,
+				"2015-01-02": `[
+					{"version":"v1","timestamp":"2015-01-02T00:00:00.000Z","event":{"country":"cn","count":3,"bytes":20}},
+					{"version":"v1","timestamp":"2015-01-02T00:00:00.000Z","event":{"country":"jp","count":1,"bytes":1}}]`,
+			})
+			defer server.Close()
+
+			query := &QueryGroupBy{
+				DataSource:   "events",
+				Intervals:    Intervals{interval}.Strings(),
+				Granularity:  GranAll,
+				Dimensions:   []DimSpec{"country"},
+				Aggregations: []Aggregation{AggCount("count"), AggLongSum("bytes", "bytes")},
+				PostAggregations: []PostAggregation{PostAggArithmetic("avg", "/", []PostAggregation{
+					PostAggFieldAccessor("bytes"), PostAggFieldAccessor("count")})},
+				Having:    HavingGreaterThan("bytes", 5),
+				LimitSpec: LimitDefault(1, []Column{{Dimension: "bytes", Direction: DirectionDESC}}),
+			}
+			client := Client{Url: server.URL}
+			err := client.QuerySplit(context.Background(), query, SplitSpec{Period: MustParsePeriod("P1D"), Workers: 2})
+			So(err, ShouldEqual, nil)
+			So(len(*got), ShouldEqual, 2)
+			So(len(query.QueryResult), ShouldEqual, 1)
+			row := query.QueryResult[0]
+			So(row.Timestamp, ShouldEqual, "2015-01-01T00:00:00.000Z")
+			So(row.Event["country"], ShouldEqual, "us")
+			bytes, err := row.Event.Int64("bytes")
+			So(err, ShouldEqual, nil)
+			So(bytes, ShouldEqual, 40)
+
+			query.LimitSpec = LimitDefault(10)
+			So(client.QuerySplit(context.Background(), query, SplitSpec{Pieces: 2}), ShouldEqual, nil)
+			So(len(query.QueryResult), ShouldEqual, 2)
+			So(query.QueryResult[0].Event, ShouldResemble, Event{"country": "cn", "count": json.Number("5"), "bytes": json.Number("30"), "avg": json.Number("6.0")})
+
+			query.Aggregations = append(query.Aggregations, AggCardinality("users", []string{"user"}))
+			So(client.QuerySplit(context.Background(), query, SplitSpec{Pieces: 2}), ShouldNotEqual, nil)
+		})
+
+		Convey("timeseries cut in the middle of a bucket", func() {
+			server, got := fakeBroker(map[string]string{
+				"2015-01-01": `[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":2}}]`,
+				"2015-01-30": `[
+					{"timestamp":"2015-01-30T12:00:00.000Z","result":{"count":3}},
+					{"timestamp":"2015-02-01T00:00:00.000Z","result":{"count":4}}]`,
+			})
+			defer server.Close()
+
+			query := &QueryTimeseries{
+				DataSource:   "events",
+				Intervals:    []string{"2015-01-01/2015-03-01"},
+				Granularity:  GranMonth,
+				Aggregations: []Aggregation{AggLongSum("count", "count")},
+			}
+			client := Client{Url: server.URL}
+			So(client.QuerySplit(context.Background(), query, SplitSpec{Pieces: 2}), ShouldEqual, nil)
+			So(len(*got), ShouldEqual, 2)
+			So(query.QueryResult, ShouldResemble, []Timeseries{
+				{Timestamp: "2015-01-01T00:00:00.000Z", Result: Event{"count": json.Number("5")}},
+				{Timestamp: "2015-02-01T00:00:00.000Z", Result: Event{"count": json.Number("4")}},
+			})
+		})
+
+		Convey("cancel on the first error", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				var req struct {
+					Intervals Intervals `json:"intervals"`
+				}
+				json.Unmarshal(body, &req)
+				if req.Intervals[0].Start.Day() == 1 {
+					http.Error(w, `{"error":"Query timeout"}`, http.StatusGatewayTimeout)
+					return
+				}
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+			}))
+			defer server.Close()
+
+			query := &QueryTimeseries{
+				DataSource:   "events",
+				Intervals:    []string{"2015-01-01/2015-01-05"},
+				Granularity:  GranDay,
+				Aggregations: []Aggregation{AggCount("count")},
+			}
+			client := Client{Url: server.URL}
+			start := time.Now()
+			err := client.QuerySplit(context.Background(), query, SplitSpec{Period: MustParsePeriod("P1D")})
+			So(ErrorClass(err), ShouldEqual, "Query timeout")
+			// The other chunks would take 5 seconds if they were not canceled.
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+		})
+
+		Convey("topN", func() {
+			server, _ := fakeBroker(map[string]string{
+				"2015-01-01": `[{"timestamp":"2015-01-01T00:00:00.000Z","result":[{"country":"cn","count":5},{"country":"us","count":4}]}]`,
+				"2015-01-02": `[{"timestamp":"2015-01-02T00:00:00.000Z","result":[{"country":"us","count":3},{"country":"jp","count":2}]}]`,
+			})
+			defer server.Close()
+
+			query := &QueryTopN{
+				DataSource:   "events",
+				Intervals:    Intervals{interval}.Strings(),
+				Granularity:  GranAll,
+				Dimension:    "country",
+				Threshold:    2,
+				Metric:       TopNMetricNumeric("count"),
+				Aggregations: []Aggregation{AggLongSum("count", "count")},
+			}
+			client := Client{Url: server.URL}
+			So(client.QuerySplit(context.Background(), query, SplitSpec{Period: MustParsePeriod("P1D")}), ShouldEqual, nil)
+			So(len(query.QueryResult), ShouldEqual, 1)
+			So(query.QueryResult[0].Result, ShouldResemble, []Event{
+				{"country": "us", "count": json.Number("7")},
+				{"country": "cn", "count": json.Number("5")},
+			})
+		})
+
+		Convey("merged numbers are rendered as Druid", func() {
+			So(toNumber(int64(7)), ShouldEqual, json.Number("7"))
+			So(toNumber(6.0), ShouldEqual, json.Number("6.0"))
+			So(toNumber(0.25), ShouldEqual, json.Number("0.25"))
+			So(toNumber(1.5e7), ShouldEqual, json.Number("1.5E7"))
+			So(toNumber(1e-4), ShouldEqual, json.Number("1.0E-4"))
+		})
+	})
+}
@@ -0,0 +1,339 @@
+package godruid
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ColumnKind tells where a column of ResultTable comes from.
+type ColumnKind int
+
+const (
+	ColumnTimestamp ColumnKind = iota
+	ColumnDimension
+	ColumnAggregation
+	ColumnPostAggregation
+	ColumnMetric // The raw metrics of select queries.
+	ColumnOther  // The columns of metadata results, e.g. minTime of timeBoundary queries.
+)
+
+func (k ColumnKind) String() string {
+	switch k {
+	case ColumnTimestamp:
+		return "timestamp"
+	case ColumnDimension:
+		return "dimension"
+	case ColumnAggregation:
+		return "aggregation"
+	case ColumnPostAggregation:
+		return "postAggregation"
+	case ColumnMetric:
+		return "metric"
+	}
+	return "other"
+}
+
+// The value types of the columns, the same as the column types of Druid.
+// An empty ValueType means the type is not known in advance.
+type ValueType string
+
+const (
+	ValueTimestamp ValueType = "TIMESTAMP"
+	ValueString    ValueType = "STRING"
+	ValueLong      ValueType = "LONG"
+	ValueFloat     ValueType = "FLOAT"
+	ValueDouble    ValueType = "DOUBLE"
+	ValueComplex   ValueType = "COMPLEX"
+)
+
+type TableColumn struct {
+	Name string
+	Kind ColumnKind
+	Type ValueType
+}
+
+// ResultTable is the results of any kind of query as a table. The timestamp column,
+// if any, goes first, and the other columns are in the order of the query, i.e. the
+// dimensions, aggregations and then post aggregations. The timestamps are time.Time,
+// and the other values are the same as the query results.
+type ResultTable struct {
+	Columns []TableColumn
+	Rows    [][]interface{}
+}
+
+// ResultRows returns the number of the rows of the results of query, the same as the rows
+// of its Table, without building the table.
+func ResultRows(query Query) int {
+	n := 0
+	switch q := query.(type) {
+	case *QueryGroupBy:
+		n = len(q.QueryResult)
+	case *QueryTimeseries:
+		n = len(q.QueryResult)
+	case *QueryTimeBoundary:
+		n = len(q.QueryResult)
+	case *QueryTopN:
+		for _, item := range q.QueryResult {
+			n += len(item.Result)
+		}
+	case *QuerySearch:
+		for _, item := range q.QueryResult {
+			n += len(item.Result)
+		}
+	case *QuerySelect:
+		n = len(q.QueryResult.Result.Events)
+	case *QueryScan:
+		for _, batch := range q.QueryResult {
+			n += len(batch.Events)
+		}
+	case *QuerySegmentMetadata:
+		for _, segment := range q.QueryResult {
+			n += len(segment.Columns)
+		}
+	}
+	return n
+}
+
+// ColumnIndex returns the index of the column, or -1 if it is not found.
+func (t *ResultTable) ColumnIndex(name string) int {
+	for i, c := range t.Columns {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Each calls fn with the rows one by one, and stops at the first error.
+func (t *ResultTable) Each(fn func(row []interface{}) error) error {
+	for _, row := range t.Rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Event returns the row i as an Event keyed by the column names.
+func (t *ResultTable) Event(i int) Event {
+	e := make(Event, len(t.Columns))
+	for j, c := range t.Columns {
+		e[c.Name] = t.Rows[i][j]
+	}
+	return e
+}
+
+func (t *ResultTable) addColumn(name string, kind ColumnKind, typ ValueType) {
+	t.Columns = append(t.Columns, TableColumn{Name: name, Kind: kind, Type: typ})
+}
+
+// addRow appends the row with the timestamp and the values of the other columns from event.
+func (t *ResultTable) addRow(timestamp string, event map[string]interface{}) error {
+	row := make([]interface{}, len(t.Columns))
+	for i, c := range t.Columns {
+		if c.Kind == ColumnTimestamp {
+			ts, err := parseISOTime(timestamp)
+			if err != nil {
+				return err
+			}
+			row[i] = ts
+			continue
+		}
+		row[i] = event[c.Name]
+	}
+	t.Rows = append(t.Rows, row)
+	return nil
+}
+
+// newResultTable returns the table with the columns of the query definitions.
+func newResultTable(timestamp bool, dims []DimSpec, aggs []Aggregation, postAggs []PostAggregation) *ResultTable {
+	t := &ResultTable{}
+	if timestamp {
+		t.addColumn("timestamp", ColumnTimestamp, ValueTimestamp)
+	}
+	for _, dim := range dims {
+		t.addColumn(dimOutputName(dim), ColumnDimension, ValueString)
+	}
+	for _, agg := range aggs {
+		t.addColumn(agg.Name, ColumnAggregation, agg.valueType())
+	}
+	for _, pa := range postAggs {
+		t.addColumn(pa.Name, ColumnPostAggregation, pa.valueType())
+	}
+	return t
+}
+
+func (q *QueryGroupBy) Table() (*ResultTable, error) {
+	t := newResultTable(true, q.Dimensions, q.Aggregations, q.PostAggregations)
+	for _, item := range q.QueryResult {
+		if err := t.addRow(item.Timestamp, item.Event); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (q *QueryTimeseries) Table() (*ResultTable, error) {
+	t := newResultTable(true, nil, q.Aggregations, q.PostAggregations)
+	for _, item := range q.QueryResult {
+		if err := t.addRow(item.Timestamp, item.Result); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Table returns the entries of all the time buckets, with the timestamps of their buckets.
+func (q *QueryTopN) Table() (*ResultTable, error) {
+	t := newResultTable(true, []DimSpec{q.Dimension}, q.Aggregations, q.PostAggregations)
+	for _, item := range q.QueryResult {
+		for _, entry := range item.Result {
+			if err := t.addRow(item.Timestamp, entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t, nil
+}
+
+// Table returns the events. If the dimensions or metrics are not specified in the query,
+// the columns are collected from the events, the string ones as dimensions and the others
+// as metrics, sorted by their names.
+func (q *QuerySelect) Table() (*ResultTable, error) {
+	t := newResultTable(true, q.Dimensions, nil, nil)
+	for _, m := range q.Metrics {
+		t.addColumn(m, ColumnMetric, "")
+	}
+	if len(q.Dimensions) == 0 || len(q.Metrics) == 0 {
+		var dims, metrics []string
+		seen := map[string]bool{"timestamp": true}
+		for _, event := range q.QueryResult.Result.Events {
+			for name, v := range event.Event {
+				if seen[name] || t.ColumnIndex(name) >= 0 {
+					continue
+				}
+				seen[name] = true
+				if _, isString := v.(string); isString && len(q.Dimensions) == 0 {
+					dims = append(dims, name)
+				} else if !isString && len(q.Metrics) == 0 {
+					metrics = append(metrics, name)
+				}
+			}
+		}
+		sort.Strings(dims)
+		sort.Strings(metrics)
+		for _, name := range dims {
+			t.addColumn(name, ColumnDimension, ValueString)
+		}
+		for _, name := range metrics {
+			t.addColumn(name, ColumnMetric, "")
+		}
+	}
+	for _, event := range q.QueryResult.Result.Events {
+		timestamp, _ := event.Event["timestamp"].(string)
+		if err := t.addRow(timestamp, event.Event); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Table returns the rows of all the batches. The columns are the ones of the query, or
+// of the first batch if not specified. The __time column, in milliseconds, goes first as
+// the timestamp column, and the types of the other columns are not known.
+func (q *QueryScan) Table() (*ResultTable, error) {
+	columns := q.Columns
+	if len(columns) == 0 && len(q.QueryResult) > 0 {
+		columns = q.QueryResult[0].Columns
+	}
+	t := &ResultTable{}
+	for _, name := range columns {
+		if name == "__time" {
+			t.Columns = append([]TableColumn{{Name: name, Kind: ColumnTimestamp, Type: ValueTimestamp}}, t.Columns...)
+		} else {
+			t.addColumn(name, ColumnOther, "")
+		}
+	}
+	for _, batch := range q.QueryResult {
+		for _, event := range batch.Events {
+			row := make([]interface{}, len(t.Columns))
+			for i, c := range t.Columns {
+				v := event[c.Name]
+				if c.Kind == ColumnTimestamp && v != nil {
+					ms, ok := toInt64(v)
+					if !ok {
+						return nil, fmt.Errorf("godruid: __time %v is not in milliseconds", v)
+					}
+					v = time.UnixMilli(ms).UTC()
+				}
+				row[i] = v
+			}
+			t.Rows = append(t.Rows, row)
+		}
+	}
+	return t, nil
+}
+
+// Table returns one row for every dimension value found.
+func (q *QuerySearch) Table() (*ResultTable, error) {
+	t := &ResultTable{}
+	t.addColumn("timestamp", ColumnTimestamp, ValueTimestamp)
+	t.addColumn("dimension", ColumnDimension, ValueString)
+	t.addColumn("value", ColumnDimension, ValueString)
+	for _, item := range q.QueryResult {
+		for _, v := range item.Result {
+			err := t.addRow(item.Timestamp, map[string]interface{}{"dimension": v.Dimension, "value": v.Value})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t, nil
+}
+
+func (q *QueryTimeBoundary) Table() (*ResultTable, error) {
+	t := &ResultTable{}
+	t.addColumn("timestamp", ColumnTimestamp, ValueTimestamp)
+	t.addColumn("minTime", ColumnOther, ValueTimestamp)
+	t.addColumn("maxTime", ColumnOther, ValueTimestamp)
+	for _, item := range q.QueryResult {
+		row := []interface{}{nil, nil, nil}
+		for i, s := range []string{item.Timestamp, item.Result.MinTime, item.Result.MaxTime} {
+			if s == "" {
+				continue // Only one bound is asked.
+			}
+			ts, err := parseISOTime(s)
+			if err != nil {
+				return nil, err
+			}
+			row[i] = ts
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, nil
+}
+
+// Table returns one row for every column of every segment.
+func (q *QuerySegmentMetadata) Table() (*ResultTable, error) {
+	t := &ResultTable{}
+	t.addColumn("id", ColumnOther, ValueString)
+	t.addColumn("intervals", ColumnOther, ValueString)
+	t.addColumn("column", ColumnOther, ValueString)
+	t.addColumn("type", ColumnOther, ValueString)
+	t.addColumn("size", ColumnOther, ValueLong)
+	t.addColumn("cardinality", ColumnOther, "")
+	for _, segment := range q.QueryResult {
+		names := make([]string, 0, len(segment.Columns))
+		for name := range segment.Columns {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			c := segment.Columns[name]
+			t.Rows = append(t.Rows, []interface{}{segment.Id, strings.Join(segment.Intervals, ","), name, c.Type, c.Size, c.Cardinality})
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,52 @@
+package godruid
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestResultTable(t *testing.T) {
+	Convey("TestResultTable", t, func() {
+		var query Query = &QueryTopN{
+			Dimension:        DimDefault("country_code", "country"),
+			Aggregations:     []Aggregation{AggLongSum("bytes", "bytes")},
+			PostAggregations: []PostAggregation{PostAggConstant("one", 1)},
+			QueryResult: []TopNItem{{
+				Timestamp: "2015-01-01T00:00:00.000Z",
+				Result:    []Event{{"country": "cn", "bytes": 2, "one": 1}, {"country": "us", "bytes": 1, "one": 1}},
+			}},
+		}
+		table, err := query.Table()
+		So(err, ShouldEqual, nil)
+		So(table.Columns, ShouldResemble, []TableColumn{
+			{"timestamp", ColumnTimestamp, ValueTimestamp},
+			{"country", ColumnDimension, ValueString},
+			{"bytes", ColumnAggregation, ValueLong},
+			{"one", ColumnPostAggregation, ValueDouble},
+		})
+		day := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+		So(table.Rows, ShouldResemble, [][]interface{}{{day, "cn", 2, 1}, {day, "us", 1, 1}})
+		So(table.Event(1)["country"], ShouldEqual, "us")
+		So(table.ColumnIndex("bytes"), ShouldEqual, 2)
+		So(ResultRows(query), ShouldEqual, len(table.Rows))
+	})
+
+	Convey("TestScanTable", t, func() {
+		query := &QueryScan{ResultFormat: "compactedList"}
+		err := query.onResponse([]byte(`[{"segmentId":"s1","columns":["country","__time","bytes"],"events":[["cn",1420070400000,2],["us",1420070400001,1]]}]`))
+		So(err, ShouldEqual, nil)
+		So(query.QueryResult[0].Events[1], ShouldResemble, Event{"country": "us", "__time": json.Number("1420070400001"), "bytes": json.Number("1")})
+		table, err := query.Table()
+		So(err, ShouldEqual, nil)
+		So(table.Columns, ShouldResemble, []TableColumn{
+			{"__time", ColumnTimestamp, ValueTimestamp},
+			{"country", ColumnOther, ""},
+			{"bytes", ColumnOther, ""},
+		})
+		day := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+		So(table.Rows[0], ShouldResemble, []interface{}{day, "cn", json.Number("2")})
+		So(ResultRows(query), ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,141 @@
+package godruid
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Trace is the details of a request to the broker.
+type Trace struct {
+	QueryId  string // The queryId in the context of the query, if any.
+	URL      string // The URL of the broker end point.
+	Request  []byte
+	Response []byte // The response body, nil if there is no response.
+	Status   int    // The HTTP status code, 0 if there is no response.
+	Attempt  int    // 1 for the first attempt of the query, and more for its retries.
+	Duration time.Duration
+	Err      error // The error of the request, or of decoding its response into the query.
+}
+
+type attemptKey struct{}
+
+// NextAttempt returns ctx for the next attempt of a query, the retry interceptors should
+// send the retries with it, so the Traces, logs and metrics know they are retries.
+func NextAttempt(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptKey{}, Attempt(ctx)+1)
+}
+
+// Attempt returns the attempt of the query sent with ctx, 1 if it's not a retry.
+func Attempt(ctx context.Context) int {
+	if n, ok := ctx.Value(attemptKey{}).(int); ok {
+		return n
+	}
+	return 1
+}
+
+type decodeHooksKey struct{}
+
+// decodeHooks are called once the response of a query is decoded into the query, so the
+// requests are traced with the decode errors.
+type decodeHooks struct {
+	query Query // The query the response is decoded into.
+	smile bool  // The response could be in Smile, as it's decoded by unmarshalResponse.
+
+	mu   sync.Mutex
+	done bool
+	fns  []func(query Query, err error)
+}
+
+// withDecodeHooks returns ctx for sending query whose response is decoded by the client.
+func withDecodeHooks(ctx context.Context, query Query) (context.Context, *decodeHooks) {
+	h := &decodeHooks{query: query}
+	return context.WithValue(ctx, decodeHooksKey{}, h), h
+}
+
+func decodeHooksFrom(ctx context.Context) *decodeHooks {
+	h, _ := ctx.Value(decodeHooksKey{}).(*decodeHooks)
+	return h
+}
+
+// AfterDecode registers fn to be called once the response of the query sent with ctx is
+// decoded, with the query decoded into and the error of the query, including the decode
+// error. It returns false and fn is not registered if the response is not decoded by the
+// client, e.g. sent by QueryRaw, or has been decoded. The interceptors which need the
+// decoded results, e.g. to count the rows, could use it.
+func AfterDecode(ctx context.Context, fn func(query Query, err error)) bool {
+	h := decodeHooksFrom(ctx)
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.done {
+		return false
+	}
+	h.fns = append(h.fns, fn)
+	return true
+}
+
+// run calls the registered functions with err, in the order they are registered.
+func (h *decodeHooks) run(err error) {
+	h.mu.Lock()
+	fns := h.fns
+	h.fns, h.done = nil, true
+	h.mu.Unlock()
+	for _, fn := range fns {
+		fn(h.query, err)
+	}
+}
+
+// Tracer receives the requests to the broker and their responses. OnRequest is called
+// before waiting for the Limiter, and OnResponse after the response is decoded into the
+// query, if it is sent by Query. The Tracer of a Client shared by goroutines is called
+// concurrently.
+type Tracer interface {
+	OnRequest(ctx context.Context, queryId string, req []byte)
+	OnResponse(ctx context.Context, trace *Trace)
+}
+
+// TracerFunc is a Tracer which only receives the responses.
+type TracerFunc func(ctx context.Context, trace *Trace)
+
+func (f TracerFunc) OnRequest(ctx context.Context, queryId string, req []byte) {}
+func (f TracerFunc) OnResponse(ctx context.Context, trace *Trace)              { f(ctx, trace) }
+
+// MultiTracer returns a Tracer which calls all the tracers in order, the nil ones are skipped.
+func MultiTracer(tracers ...Tracer) Tracer {
+	var ts multiTracer
+	for _, t := range tracers {
+		if t != nil {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+type multiTracer []Tracer
+
+func (ts multiTracer) OnRequest(ctx context.Context, queryId string, req []byte) {
+	for _, t := range ts {
+		t.OnRequest(ctx, queryId, req)
+	}
+}
+
+func (ts multiTracer) OnResponse(ctx context.Context, trace *Trace) {
+	for _, t := range ts {
+		t.OnResponse(ctx, trace)
+	}
+}
+
+// queryIdOf returns the queryId in the context of the query JSON.
+func queryIdOf(req []byte) string {
+	var query struct {
+		Context struct {
+			QueryId string `json:"queryId"`
+		} `json:"context"`
+	}
+	json.Unmarshal(req, &query)
+	return query.Context.QueryId
+}
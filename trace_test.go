@@ -0,0 +1,64 @@
+package godruid
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+type recordTracer struct {
+	mu       sync.Mutex
+	requests []string
+	traces   []*Trace
+}
+
+func (t *recordTracer) OnRequest(ctx context.Context, queryId string, req []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests = append(t.requests, queryId)
+}
+
+func (t *recordTracer) OnResponse(ctx context.Context, trace *Trace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.traces = append(t.traces, trace)
+}
+
+func TestTracer(t *testing.T) {
+	Convey("TestTracer", t, func() {
+		server, _ := fakeBroker(map[string]string{
+			"2015-01-01": `[{"timestamp":"2015-01-01T00:00:00.000Z","result":{"count":3}}]`,
+		})
+		defer server.Close()
+
+		tracer := &recordTracer{}
+		client := Client{Url: server.URL, Debug: true, Tracer: tracer}
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client.Query(&QueryTimeseries{
+					DataSource:   "events",
+					Intervals:    []string{"2015-01-01/2015-01-02"},
+					Granularity:  GranAll,
+					Aggregations: []Aggregation{AggCount("count")},
+					Context:      &QueryContext{QueryId: "q1"},
+				})
+			}()
+		}
+		wg.Wait()
+
+		So(tracer.requests, ShouldResemble, []string{"q1", "q1", "q1", "q1"})
+		So(len(tracer.traces), ShouldEqual, 4)
+		trace := tracer.traces[0]
+		So(trace.QueryId, ShouldEqual, "q1")
+		So(trace.Status, ShouldEqual, http.StatusOK)
+		So(trace.Err, ShouldEqual, nil)
+		So(string(trace.Response), ShouldContainSubstring, `"count":3`)
+		So(string(trace.Request), ShouldContainSubstring, `"queryType": "timeseries"`)
+		So(trace.Duration, ShouldBeGreaterThan, 0)
+	})
+}